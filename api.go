@@ -33,7 +33,7 @@ type CancelWithdrawalResponse struct {
 // Permissions required: <code>Perm_W_Withdrawals</code>
 func (cl *Client) CancelWithdrawal(ctx context.Context, req *CancelWithdrawalRequest) (*CancelWithdrawalResponse, error) {
 	var res CancelWithdrawalResponse
-	err := cl.do(ctx, "DELETE", "/api/1/withdrawals/{id}", req, &res, true)
+	err := cl.do(ctx, "CancelWithdrawal", "DELETE", "/api/1/withdrawals/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +77,7 @@ type CreateAccountResponse struct {
 // Permissions required: <code>Perm_W_Addresses</code>
 func (cl *Client) CreateAccount(ctx context.Context, req *CreateAccountRequest) (*CreateAccountResponse, error) {
 	var res CreateAccountResponse
-	err := cl.do(ctx, "POST", "/api/1/accounts", req, &res, true)
+	err := cl.do(ctx, "CreateAccount", "POST", "/api/1/accounts", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +116,7 @@ type CreateFundingAddressResponse struct {
 // Permissions required: <code>Perm_W_Addresses</code>
 func (cl *Client) CreateFundingAddress(ctx context.Context, req *CreateFundingAddressRequest) (*CreateFundingAddressResponse, error) {
 	var res CreateFundingAddressResponse
-	err := cl.do(ctx, "POST", "/api/1/funding_address", req, &res, true)
+	err := cl.do(ctx, "CreateFundingAddress", "POST", "/api/1/funding_address", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +181,7 @@ type CreateQuoteResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) CreateQuote(ctx context.Context, req *CreateQuoteRequest) (*CreateQuoteResponse, error) {
 	var res CreateQuoteResponse
-	err := cl.do(ctx, "POST", "/api/1/quotes", req, &res, true)
+	err := cl.do(ctx, "CreateQuote", "POST", "/api/1/quotes", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +228,7 @@ type CreateWithdrawalResponse struct {
 // Permissions required: <code>Perm_W_Withdrawals</code>
 func (cl *Client) CreateWithdrawal(ctx context.Context, req *CreateWithdrawalRequest) (*CreateWithdrawalResponse, error) {
 	var res CreateWithdrawalResponse
-	err := cl.do(ctx, "POST", "/api/1/withdrawals", req, &res, true)
+	err := cl.do(ctx, "CreateWithdrawal", "POST", "/api/1/withdrawals", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +264,7 @@ type DiscardQuoteResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) DiscardQuote(ctx context.Context, req *DiscardQuoteRequest) (*DiscardQuoteResponse, error) {
 	var res DiscardQuoteResponse
-	err := cl.do(ctx, "DELETE", "/api/1/quotes/{id}", req, &res, true)
+	err := cl.do(ctx, "DiscardQuote", "DELETE", "/api/1/quotes/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -304,7 +304,7 @@ type ExerciseQuoteResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) ExerciseQuote(ctx context.Context, req *ExerciseQuoteRequest) (*ExerciseQuoteResponse, error) {
 	var res ExerciseQuoteResponse
-	err := cl.do(ctx, "PUT", "/api/1/quotes/{id}", req, &res, true)
+	err := cl.do(ctx, "ExerciseQuote", "PUT", "/api/1/quotes/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -330,7 +330,7 @@ type GetBalancesResponse struct {
 // Permissions required: <code>Perm_R_Balance</code>
 func (cl *Client) GetBalances(ctx context.Context, req *GetBalancesRequest) (*GetBalancesResponse, error) {
 	var res GetBalancesResponse
-	err := cl.do(ctx, "GET", "/api/1/balance", req, &res, true)
+	err := cl.do(ctx, "GetBalances", "GET", "/api/1/balance", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -360,7 +360,7 @@ type GetFeeInfoResponse struct {
 // Permissions required: <code>Perm_R_Orders</code>
 func (cl *Client) GetFeeInfo(ctx context.Context, req *GetFeeInfoRequest) (*GetFeeInfoResponse, error) {
 	var res GetFeeInfoResponse
-	err := cl.do(ctx, "GET", "/api/1/fee_info", req, &res, true)
+	err := cl.do(ctx, "GetFeeInfo", "GET", "/api/1/fee_info", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -403,7 +403,7 @@ type GetFundingAddressResponse struct {
 // Permissions required: <code>Perm_R_Addresses</code>
 func (cl *Client) GetFundingAddress(ctx context.Context, req *GetFundingAddressRequest) (*GetFundingAddressResponse, error) {
 	var res GetFundingAddressResponse
-	err := cl.do(ctx, "GET", "/api/1/funding_address", req, &res, true)
+	err := cl.do(ctx, "GetFundingAddress", "GET", "/api/1/funding_address", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -452,7 +452,7 @@ type GetOrderResponse struct {
 // Permissions required: <code>Perm_R_Orders</code>
 func (cl *Client) GetOrder(ctx context.Context, req *GetOrderRequest) (*GetOrderResponse, error) {
 	var res GetOrderResponse
-	err := cl.do(ctx, "GET", "/api/1/orders/{id}", req, &res, true)
+	err := cl.do(ctx, "GetOrder", "GET", "/api/1/orders/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -481,7 +481,7 @@ type GetOrderBookResponse struct {
 // multiple orders at the same price are not necessarily conflated.
 func (cl *Client) GetOrderBook(ctx context.Context, req *GetOrderBookRequest) (*GetOrderBookResponse, error) {
 	var res GetOrderBookResponse
-	err := cl.do(ctx, "GET", "/api/1/orderbook", req, &res, false)
+	err := cl.do(ctx, "GetOrderBook", "GET", "/api/1/orderbook", req, &res, false)
 	if err != nil {
 		return nil, err
 	}
@@ -516,7 +516,7 @@ type GetQuoteResponse struct {
 // Permissions required: <code>Perm_R_Orders</code>
 func (cl *Client) GetQuote(ctx context.Context, req *GetQuoteRequest) (*GetQuoteResponse, error) {
 	var res GetQuoteResponse
-	err := cl.do(ctx, "GET", "/api/1/quotes/{id}", req, &res, true)
+	err := cl.do(ctx, "GetQuote", "GET", "/api/1/quotes/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -546,7 +546,7 @@ type GetTickerResponse struct {
 // Returns the latest ticker indicators.
 func (cl *Client) GetTicker(ctx context.Context, req *GetTickerRequest) (*GetTickerResponse, error) {
 	var res GetTickerResponse
-	err := cl.do(ctx, "GET", "/api/1/ticker", req, &res, false)
+	err := cl.do(ctx, "GetTicker", "GET", "/api/1/ticker", req, &res, false)
 	if err != nil {
 		return nil, err
 	}
@@ -567,7 +567,7 @@ type GetTickersResponse struct {
 // Returns the latest ticker indicators from all active Luno exchanges.
 func (cl *Client) GetTickers(ctx context.Context, req *GetTickersRequest) (*GetTickersResponse, error) {
 	var res GetTickersResponse
-	err := cl.do(ctx, "GET", "/api/1/tickers", req, &res, false)
+	err := cl.do(ctx, "GetTickers", "GET", "/api/1/tickers", req, &res, false)
 	if err != nil {
 		return nil, err
 	}
@@ -600,7 +600,7 @@ type GetWithdrawalResponse struct {
 // Permissions required: <code>Perm_R_Withdrawals</code>
 func (cl *Client) GetWithdrawal(ctx context.Context, req *GetWithdrawalRequest) (*GetWithdrawalResponse, error) {
 	var res GetWithdrawalResponse
-	err := cl.do(ctx, "GET", "/api/1/withdrawals/{id}", req, &res, true)
+	err := cl.do(ctx, "GetWithdrawal", "GET", "/api/1/withdrawals/{id}", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -637,7 +637,7 @@ type ListOrdersResponse struct {
 // Permissions required: <code>Perm_R_Orders</code>
 func (cl *Client) ListOrders(ctx context.Context, req *ListOrdersRequest) (*ListOrdersResponse, error) {
 	var res ListOrdersResponse
-	err := cl.do(ctx, "GET", "/api/1/listorders", req, &res, true)
+	err := cl.do(ctx, "ListOrders", "GET", "/api/1/listorders", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -676,7 +676,7 @@ type ListPendingTransactionsResponse struct {
 // Permissions required: <code>Perm_R_Transactions</code>
 func (cl *Client) ListPendingTransactions(ctx context.Context, req *ListPendingTransactionsRequest) (*ListPendingTransactionsResponse, error) {
 	var res ListPendingTransactionsResponse
-	err := cl.do(ctx, "GET", "/api/1/accounts/{id}/pending", req, &res, true)
+	err := cl.do(ctx, "ListPendingTransactions", "GET", "/api/1/accounts/{id}/pending", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -706,7 +706,7 @@ type ListTradesResponse struct {
 // per call.
 func (cl *Client) ListTrades(ctx context.Context, req *ListTradesRequest) (*ListTradesResponse, error) {
 	var res ListTradesResponse
-	err := cl.do(ctx, "GET", "/api/1/trades", req, &res, false)
+	err := cl.do(ctx, "ListTrades", "GET", "/api/1/trades", req, &res, false)
 	if err != nil {
 		return nil, err
 	}
@@ -761,7 +761,7 @@ type ListTransactionsResponse struct {
 // Permissions required: <code>Perm_R_Transactions</code>
 func (cl *Client) ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error) {
 	var res ListTransactionsResponse
-	err := cl.do(ctx, "GET", "/api/1/accounts/{id}/transactions", req, &res, true)
+	err := cl.do(ctx, "ListTransactions", "GET", "/api/1/accounts/{id}/transactions", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -804,7 +804,7 @@ type ListUserTradesResponse struct {
 // Permissions required: <code>Perm_R_Orders</code>
 func (cl *Client) ListUserTrades(ctx context.Context, req *ListUserTradesRequest) (*ListUserTradesResponse, error) {
 	var res ListUserTradesResponse
-	err := cl.do(ctx, "GET", "/api/1/listtrades", req, &res, true)
+	err := cl.do(ctx, "ListUserTrades", "GET", "/api/1/listtrades", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -813,6 +813,11 @@ func (cl *Client) ListUserTrades(ctx context.Context, req *ListUserTradesRequest
 
 // ListWithdrawalsRequest is the request struct for ListWithdrawals.
 type ListWithdrawalsRequest struct {
+	// Filter to withdrawals requested before this ID
+	BeforeId int64 `json:"before_id" url:"before_id"`
+
+	// Limit to this many withdrawals
+	Limit int64 `json:"limit" url:"limit"`
 }
 
 // ListWithdrawalsResponse is the response struct for ListWithdrawals.
@@ -827,7 +832,7 @@ type ListWithdrawalsResponse struct {
 // Permissions required: <code>Perm_R_Withdrawals</code>
 func (cl *Client) ListWithdrawals(ctx context.Context, req *ListWithdrawalsRequest) (*ListWithdrawalsResponse, error) {
 	var res ListWithdrawalsResponse
-	err := cl.do(ctx, "GET", "/api/1/withdrawals", req, &res, true)
+	err := cl.do(ctx, "ListWithdrawals", "GET", "/api/1/withdrawals", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -892,7 +897,7 @@ type PostLimitOrderResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) PostLimitOrder(ctx context.Context, req *PostLimitOrderRequest) (*PostLimitOrderResponse, error) {
 	var res PostLimitOrderResponse
-	err := cl.do(ctx, "POST", "/api/1/postorder", req, &res, true)
+	err := cl.do(ctx, "PostLimitOrder", "POST", "/api/1/postorder", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -950,7 +955,7 @@ type PostMarketOrderResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) PostMarketOrder(ctx context.Context, req *PostMarketOrderRequest) (*PostMarketOrderResponse, error) {
 	var res PostMarketOrderResponse
-	err := cl.do(ctx, "POST", "/api/1/marketorder", req, &res, true)
+	err := cl.do(ctx, "PostMarketOrder", "POST", "/api/1/marketorder", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -1010,7 +1015,7 @@ type SendResponse struct {
 // Permissions required: <code>Perm_W_Send</code>
 func (cl *Client) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
 	var res SendResponse
-	err := cl.do(ctx, "POST", "/api/1/send", req, &res, true)
+	err := cl.do(ctx, "Send", "POST", "/api/1/send", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
@@ -1037,7 +1042,7 @@ type StopOrderResponse struct {
 // Permissions required: <code>Perm_W_Orders</code>
 func (cl *Client) StopOrder(ctx context.Context, req *StopOrderRequest) (*StopOrderResponse, error) {
 	var res StopOrderResponse
-	err := cl.do(ctx, "POST", "/api/1/stoporder", req, &res, true)
+	err := cl.do(ctx, "StopOrder", "POST", "/api/1/stoporder", req, &res, true)
 	if err != nil {
 		return nil, err
 	}
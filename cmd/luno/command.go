@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// command is a CLI command which calls one Client method. The commands are
+// generated from api.go by internal/apigen.
+type command struct {
+	name    string
+	summary string
+	flags   []flagDoc
+
+	newRequest func() interface{}
+	call       func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error)
+}
+
+// flagDoc documents a request field which can be set with a flag of the same
+// name as its url tag.
+type flagDoc struct {
+	name     string
+	usage    string
+	required bool
+}
+
+// aliases are short names for common commands. Two-word aliases group
+// commands by resource, e.g. "orders place".
+var aliases = map[string]string{
+	"ticker":       "get-ticker",
+	"tickers":      "get-tickers",
+	"book":         "get-order-book",
+	"trades":       "list-trades",
+	"balances":     "get-balances",
+	"fees":         "get-fee-info",
+	"transactions": "list-transactions",
+	"pending":      "list-pending-transactions",
+
+	"accounts create": "create-account",
+
+	"orders list":   "list-orders",
+	"orders get":    "get-order",
+	"orders place":  "post-limit-order",
+	"orders market": "post-market-order",
+	"orders stop":   "stop-order",
+	"orders trades": "list-user-trades",
+
+	"withdrawals":        "list-withdrawals",
+	"withdrawals list":   "list-withdrawals",
+	"withdrawals get":    "get-withdrawal",
+	"withdrawals create": "create-withdrawal",
+	"withdrawals cancel": "cancel-withdrawal",
+
+	"quotes create":   "create-quote",
+	"quotes get":      "get-quote",
+	"quotes exercise": "exercise-quote",
+	"quotes discard":  "discard-quote",
+
+	"address":        "get-funding-address",
+	"address create": "create-funding-address",
+}
+
+// findCommand returns the command named by the first one or two args and the
+// remaining args.
+func findCommand(args []string) (*command, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("no command given")
+	}
+	name, rest := args[0], args[1:]
+	if len(args) > 1 {
+		if n, ok := aliases[args[0]+" "+args[1]]; ok {
+			name, rest = n, args[2:]
+		}
+	}
+	if n, ok := aliases[name]; ok {
+		name = n
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return c, rest, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+// parse sets the fields of a new request from command-line flags.
+func (c *command) parse(args []string, stderr io.Writer) (interface{}, error) {
+	req := c.newRequest()
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: luno %s [flags]\n\n%s\n\n", c.name, c.summary)
+		fs.PrintDefaults()
+	}
+
+	fields := requestFields(req)
+	for _, fd := range c.flags {
+		usage := fd.usage
+		if fd.required {
+			usage += " (required)"
+		}
+		fs.Var(fieldValue{fields[fd.name]}, fd.name, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var missing []string
+	for _, fd := range c.flags {
+		if fd.required && !set[fd.name] {
+			missing = append(missing, "-"+fd.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s: missing required flags %s",
+			c.name, strings.Join(missing, ", "))
+	}
+	return req, nil
+}
+
+// requestFields returns the fields of the struct pointed to by req, by url
+// tag.
+func requestFields(req interface{}) map[string]reflect.Value {
+	v := reflect.ValueOf(req).Elem()
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		if tag := v.Type().Field(i).Tag.Get("url"); tag != "" && tag != "-" {
+			fields[tag] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// fieldValue is a flag.Value which sets a request field.
+type fieldValue struct {
+	v reflect.Value
+}
+
+var decimalType = reflect.TypeOf(decimal.Decimal{})
+
+func (f fieldValue) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	if f.v.Type() == decimalType {
+		d := f.v.Interface().(decimal.Decimal)
+		if d.Sign() == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	if f.v.Kind() == reflect.Slice {
+		return strings.Join(f.v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f fieldValue) Set(s string) error {
+	if f.v.Type() == decimalType {
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return err
+		}
+		f.v.Set(reflect.ValueOf(d))
+		return nil
+	}
+	switch f.v.Kind() {
+	case reflect.String:
+		f.v.SetString(s)
+	case reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(i)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case reflect.Slice:
+		// Slices accept comma separated values and repeated flags.
+		for _, e := range strings.Split(s, ",") {
+			f.v.Set(reflect.Append(f.v, reflect.ValueOf(e)))
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", f.v.Type())
+	}
+	return nil
+}
+
+// IsBoolFlag allows boolean fields to be set without a value, e.g.
+// -post_only.
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
+
+// printCommands writes the list of commands and aliases.
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-26s %s\n", c.name, c.summary)
+	}
+
+	var names []string
+	for a := range aliases {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "\nAliases:")
+	for _, a := range names {
+		fmt.Fprintf(w, "  %-26s %s\n", a, aliases[a])
+	}
+}
@@ -0,0 +1,330 @@
+// Code generated by internal/apigen. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+
+	luno "github.com/luno/luno-go"
+)
+
+var commands = []*command{
+	{
+		name:    "cancel-withdrawal",
+		summary: "Cancel a withdrawal request.",
+		flags: []flagDoc{
+			{name: "id", usage: "ID of the withdrawal to cancel.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.CancelWithdrawalRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.CancelWithdrawal(ctx, req.(*luno.CancelWithdrawalRequest))
+		},
+	},
+	{
+		name:    "create-account",
+		summary: "Create an additional account for the specified currency.",
+		flags: []flagDoc{
+			{name: "currency", usage: "The currency code for the account you want to create", required: true},
+			{name: "name", usage: "The label to use for this account", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.CreateAccountRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.CreateAccount(ctx, req.(*luno.CreateAccountRequest))
+		},
+	},
+	{
+		name:    "create-funding-address",
+		summary: "Allocates a new receive address to your account.",
+		flags: []flagDoc{
+			{name: "asset", usage: "Currency code of the asset.", required: true},
+			{name: "name", usage: "An optional name for the new address", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.CreateFundingAddressRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.CreateFundingAddress(ctx, req.(*luno.CreateFundingAddressRequest))
+		},
+	},
+	{
+		name:    "create-quote",
+		summary: "Creates a new quote to buy or sell a particular amount.",
+		flags: []flagDoc{
+			{name: "base_amount", usage: "Amount to buy or sell in the pair base currency.", required: true},
+			{name: "pair", usage: "Currency pair to trade.", required: true},
+			{name: "type", usage: "BUY or SELL.", required: true},
+			{name: "base_account_id", usage: "Optional account for the pair's base currency.", required: false},
+			{name: "counter_account_id", usage: "Optional account for the pair's counter currency.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.CreateQuoteRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.CreateQuote(ctx, req.(*luno.CreateQuoteRequest))
+		},
+	},
+	{
+		name:    "create-withdrawal",
+		summary: "Creates a new withdrawal request.",
+		flags: []flagDoc{
+			{name: "amount", usage: "Amount to withdraw.", required: true},
+			{name: "type", usage: "Withdrawal type.", required: true},
+			{name: "beneficiary_id", usage: "The beneficiary ID of the bank account the withdrawal will be paid out to.", required: false},
+			{name: "reference", usage: "For internal use.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.CreateWithdrawalRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.CreateWithdrawal(ctx, req.(*luno.CreateWithdrawalRequest))
+		},
+	},
+	{
+		name:    "discard-quote",
+		summary: "Discard a quote.",
+		flags: []flagDoc{
+			{name: "id", usage: "ID of the quote to discard.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.DiscardQuoteRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.DiscardQuote(ctx, req.(*luno.DiscardQuoteRequest))
+		},
+	},
+	{
+		name:    "exercise-quote",
+		summary: "Exercise a quote to perform the trade.",
+		flags: []flagDoc{
+			{name: "id", usage: "ID of the quote to exercise.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.ExerciseQuoteRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ExerciseQuote(ctx, req.(*luno.ExerciseQuoteRequest))
+		},
+	},
+	{
+		name:    "get-balances",
+		summary: "Return the list of all accounts and their respective balances.",
+		flags: []flagDoc{
+			{name: "assets", usage: "Only return balances for wallets with these currencies (if not provided, all balances will be returned)", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.GetBalancesRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetBalances(ctx, req.(*luno.GetBalancesRequest))
+		},
+	},
+	{
+		name:    "get-fee-info",
+		summary: "Returns your fees and 30 day trading volume (as of midnight) for a given pair.",
+		flags: []flagDoc{
+			{name: "pair", usage: "Get fee information about this pair.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetFeeInfoRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetFeeInfo(ctx, req.(*luno.GetFeeInfoRequest))
+		},
+	},
+	{
+		name:    "get-funding-address",
+		summary: "Returns the default receive address associated with your account and the amount received via the address.",
+		flags: []flagDoc{
+			{name: "asset", usage: "Currency code of the asset.", required: true},
+			{name: "address", usage: "Specific Bitcoin or Ethereum address to retrieve.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.GetFundingAddressRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetFundingAddress(ctx, req.(*luno.GetFundingAddressRequest))
+		},
+	},
+	{
+		name:    "get-order",
+		summary: "Get an order by its ID.",
+		flags: []flagDoc{
+			{name: "id", usage: "The order ID.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetOrderRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetOrder(ctx, req.(*luno.GetOrderRequest))
+		},
+	},
+	{
+		name:    "get-order-book",
+		summary: "Returns a list of bids and asks in the order book.",
+		flags: []flagDoc{
+			{name: "pair", usage: "Currency pair", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetOrderBookRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetOrderBook(ctx, req.(*luno.GetOrderBookRequest))
+		},
+	},
+	{
+		name:    "get-quote",
+		summary: "Get the latest status of a quote.",
+		flags: []flagDoc{
+			{name: "id", usage: "ID of the quote to retrieve.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetQuoteRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetQuote(ctx, req.(*luno.GetQuoteRequest))
+		},
+	},
+	{
+		name:    "get-ticker",
+		summary: "Returns the latest ticker indicators.",
+		flags: []flagDoc{
+			{name: "pair", usage: "Currency pair", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetTickerRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetTicker(ctx, req.(*luno.GetTickerRequest))
+		},
+	},
+	{
+		name:       "get-tickers",
+		summary:    "Returns the latest ticker indicators from all active Luno exchanges.",
+		flags:      []flagDoc{},
+		newRequest: func() interface{} { return new(luno.GetTickersRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetTickers(ctx, req.(*luno.GetTickersRequest))
+		},
+	},
+	{
+		name:    "get-withdrawal",
+		summary: "Returns the status of a particular withdrawal request.",
+		flags: []flagDoc{
+			{name: "id", usage: "Withdrawal ID to retrieve.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.GetWithdrawalRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.GetWithdrawal(ctx, req.(*luno.GetWithdrawalRequest))
+		},
+	},
+	{
+		name:    "list-orders",
+		summary: "Returns a list of the most recently placed orders.",
+		flags: []flagDoc{
+			{name: "created_before", usage: "Filter to orders created before this timestamp (Unix milliseconds)", required: false},
+			{name: "limit", usage: "Limit to this many orders", required: false},
+			{name: "pair", usage: "Filter to only orders of this currency pair", required: false},
+			{name: "state", usage: "Filter to only orders of this state", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.ListOrdersRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListOrders(ctx, req.(*luno.ListOrdersRequest))
+		},
+	},
+	{
+		name:    "list-pending-transactions",
+		summary: "Return a list of all pending transactions related to the account.",
+		flags: []flagDoc{
+			{name: "id", usage: "Account ID", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.ListPendingTransactionsRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListPendingTransactions(ctx, req.(*luno.ListPendingTransactionsRequest))
+		},
+	},
+	{
+		name:    "list-trades",
+		summary: "Returns a list of the most recent trades.",
+		flags: []flagDoc{
+			{name: "pair", usage: "Currency pair", required: true},
+			{name: "since", usage: "Fetch trades executed after this time, specified as a Unix timestamp in milliseconds.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.ListTradesRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListTrades(ctx, req.(*luno.ListTradesRequest))
+		},
+	},
+	{
+		name:    "list-transactions",
+		summary: "Return a list of transaction entries from an account.",
+		flags: []flagDoc{
+			{name: "id", usage: "Account ID", required: true},
+			{name: "max_row", usage: "Maximum of the row range to return (exclusive)", required: true},
+			{name: "min_row", usage: "Minimum of the row range to return (inclusive)", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.ListTransactionsRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListTransactions(ctx, req.(*luno.ListTransactionsRequest))
+		},
+	},
+	{
+		name:    "list-user-trades",
+		summary: "Returns a list of your recent trades for a given pair, sorted by oldest first.",
+		flags: []flagDoc{
+			{name: "pair", usage: "Filter to trades of this currency pair.", required: true},
+			{name: "limit", usage: "Limit to this number of trades (default 100).", required: false},
+			{name: "since", usage: "Filter to trades on or after this timestamp.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.ListUserTradesRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListUserTrades(ctx, req.(*luno.ListUserTradesRequest))
+		},
+	},
+	{
+		name:    "list-withdrawals",
+		summary: "Returns a list of withdrawal requests.",
+		flags: []flagDoc{
+			{name: "before_id", usage: "Filter to withdrawals requested before this ID", required: false},
+			{name: "limit", usage: "Limit to this many withdrawals", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.ListWithdrawalsRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.ListWithdrawals(ctx, req.(*luno.ListWithdrawalsRequest))
+		},
+	},
+	{
+		name:    "post-limit-order",
+		summary: "Create a new trade order.",
+		flags: []flagDoc{
+			{name: "pair", usage: "The currency pair to trade.", required: true},
+			{name: "price", usage: "Limit price as a decimal string in units of ZAR/BTC.", required: true},
+			{name: "type", usage: "BID for a bid (buy) limit order ASK for ab ask (sell) limit order", required: true},
+			{name: "volume", usage: "Amount of Bitcoin or Ethereum to buy or sell as a decimal string in units of the currency.", required: true},
+			{name: "base_account_id", usage: "The base currency account to use in the trade.", required: false},
+			{name: "counter_account_id", usage: "The counter currency account to use in the trade.", required: false},
+			{name: "post_only", usage: "Post-only orders will be cancelled if they would otherwise have traded immediately.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.PostLimitOrderRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.PostLimitOrder(ctx, req.(*luno.PostLimitOrderRequest))
+		},
+	},
+	{
+		name:    "post-market-order",
+		summary: "Create a new market order.",
+		flags: []flagDoc{
+			{name: "pair", usage: "The currency pair to trade.", required: true},
+			{name: "type", usage: "BUY to buy Bitcoin or Ethereum SELL to sell Bitcoin or Ethereum", required: true},
+			{name: "base_account_id", usage: "The base currency account to use in the trade.", required: false},
+			{name: "base_volume", usage: "For a SELL order: amount of Bitcoin to sell as a decimal string in units of BTC or ETH.", required: false},
+			{name: "counter_account_id", usage: "The counter currency account to use in the trade.", required: false},
+			{name: "counter_volume", usage: "For a BUY order: amount of local currency (e.g. ZAR, MYR) to spend as a decimal string in units of the local currency.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.PostMarketOrderRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.PostMarketOrder(ctx, req.(*luno.PostMarketOrderRequest))
+		},
+	},
+	{
+		name:    "send",
+		summary: "Send Bitcoin from your account to a Bitcoin address or email address.",
+		flags: []flagDoc{
+			{name: "address", usage: "Destination Bitcoin address or email address, or Ethereum address to send to.", required: true},
+			{name: "amount", usage: "Amount to send as a decimal string.", required: true},
+			{name: "currency", usage: "Currency to send.", required: true},
+			{name: "description", usage: "Description for the transaction to record on the account statement.", required: false},
+			{name: "message", usage: "Message to send to the recipient.", required: false},
+		},
+		newRequest: func() interface{} { return new(luno.SendRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.Send(ctx, req.(*luno.SendRequest))
+		},
+	},
+	{
+		name:    "stop-order",
+		summary: "Request to stop an order.",
+		flags: []flagDoc{
+			{name: "order_id", usage: "The order reference as a string.", required: true},
+		},
+		newRequest: func() interface{} { return new(luno.StopOrderRequest) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.StopOrder(ctx, req.(*luno.StopOrderRequest))
+		},
+	},
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// config holds the credentials and endpoint used by the CLI. Values are taken
+// from command-line flags, then environment variables, then the config file.
+type config struct {
+	APIKeyID     string `json:"api_key_id"`
+	APIKeySecret string `json:"api_key_secret"`
+	BaseURL      string `json:"base_url"`
+}
+
+// Environment variables read by the CLI.
+const (
+	envAPIKeyID     = "LUNO_API_KEY_ID"
+	envAPIKeySecret = "LUNO_API_KEY_SECRET"
+	envBaseURL      = "LUNO_BASE_URL"
+	envConfig       = "LUNO_CONFIG"
+)
+
+// defaultConfigPath returns the config file used when none is given, or an
+// empty string if there is no home directory.
+func defaultConfigPath(getenv func(string) string) string {
+	home := getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".luno", "config.json")
+}
+
+// loadConfig reads the config file at path. A missing file is only an error
+// if the path was given explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return c, nil
+	} else if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// merge fills the empty values of c from others, in order.
+func (c *config) merge(others ...config) {
+	for _, o := range others {
+		if c.APIKeyID == "" {
+			c.APIKeyID = o.APIKeyID
+		}
+		if c.APIKeySecret == "" {
+			c.APIKeySecret = o.APIKeySecret
+		}
+		if c.BaseURL == "" {
+			c.BaseURL = o.BaseURL
+		}
+	}
+}
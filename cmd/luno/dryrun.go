@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	luno "github.com/luno/luno-go"
+)
+
+// errDryRun stops a request from being sent in dry-run mode.
+var errDryRun = errors.New("dry run")
+
+// dryRunTransport prints requests instead of sending them. Credentials are
+// redacted.
+type dryRunTransport struct {
+	w io.Writer
+}
+
+func (t dryRunTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.w, "%s %s\n", r.Method, r.URL)
+
+	var keys []string
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := r.Header.Get(k)
+		if k == "Authorization" {
+			v = luno.Redacted
+		}
+		fmt.Fprintf(t.w, "%s: %s\n", k, v)
+	}
+
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			fmt.Fprintf(t.w, "\n%s\n", b)
+		}
+	}
+	return nil, errDryRun
+}
@@ -0,0 +1,134 @@
+// Command luno is a command-line client for the Luno API.
+//
+// Usage:
+//
+//	luno [flags] <command> [command flags]
+//
+// Every Client method has a command named after it, e.g. get-order-book for
+// GetOrderBook, whose flags are the fields of the method's request. Common
+// commands also have short aliases, e.g.:
+//
+//	luno ticker -pair XBTZAR
+//	luno -output json orders place -pair XBTZAR -type BID -price 100000 -volume 0.01
+//	luno -dry_run withdrawals create -type ZAR_EFT -amount 100
+//
+// Credentials are read from the -api_key_id and -api_key_secret flags, the
+// LUNO_API_KEY_ID and LUNO_API_KEY_SECRET environment variables or a JSON
+// config file, in that order. The config file is $HOME/.luno/config.json
+// unless another is given with -config or LUNO_CONFIG:
+//
+//	{"api_key_id": "...", "api_key_secret": "..."}
+//
+// Run luno -help for the list of commands and luno <command> -help for the
+// flags of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	luno "github.com/luno/luno-go"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Getenv, os.Stdout, os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "luno:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the CLI with the given arguments and environment.
+func run(args []string, getenv func(string) string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("luno", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		flags      config
+		configPath = fs.String("config", "", "Config file (default $HOME/.luno/config.json)")
+		output     = fs.String("output", formatTable, "Output format: table, json or csv")
+		dryRun     = fs.Bool("dry_run", false, "Print the request instead of sending it")
+		debug      = fs.Bool("debug", false, "Enable debug logging")
+		timeout    = fs.Duration("timeout", 30*time.Second, "Timeout for the call")
+	)
+	fs.StringVar(&flags.APIKeyID, "api_key_id", "", "Luno API key ID")
+	fs.StringVar(&flags.APIKeySecret, "api_key_secret", "", "Luno API key secret")
+	fs.StringVar(&flags.BaseURL, "base_url", "", "Base URL of the API")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: luno [flags] <command> [command flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		printCommands(stderr)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	switch *output {
+	case formatTable, formatJSON, formatCSV:
+	default:
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	cmd, cmdArgs, err := findCommand(fs.Args())
+	if err != nil {
+		return err
+	}
+	req, err := cmd.parse(cmdArgs, stderr)
+	if err != nil {
+		return err
+	}
+
+	path, explicit := *configPath, true
+	if path == "" {
+		path = getenv(envConfig)
+	}
+	if path == "" {
+		path, explicit = defaultConfigPath(getenv), false
+	}
+	file, err := loadConfig(path, explicit)
+	if err != nil {
+		return err
+	}
+	cfg := flags
+	cfg.merge(config{
+		APIKeyID:     getenv(envAPIKeyID),
+		APIKeySecret: getenv(envAPIKeySecret),
+		BaseURL:      getenv(envBaseURL),
+	}, file)
+
+	cl := luno.NewClient()
+	cl.SetDebug(*debug)
+	if cfg.BaseURL != "" {
+		cl.SetBaseURL(cfg.BaseURL)
+	}
+	if cfg.APIKeyID != "" || cfg.APIKeySecret != "" {
+		if err := cl.SetAuth(cfg.APIKeyID, cfg.APIKeySecret); err != nil {
+			return err
+		}
+	}
+	if *dryRun {
+		cl.SetHTTPClient(&http.Client{Transport: dryRunTransport{stdout}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	res, err := cmd.call(ctx, cl, req)
+	if *dryRun && errors.Is(err, errDryRun) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return writeResult(stdout, *output, res)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"github.com/luno/luno-go/lunotest"
+)
+
+func dec(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// runCLI runs the CLI against srv with credentials in the environment and
+// returns its output.
+func runCLI(t *testing.T, srv *lunotest.Server, args ...string) (string, error) {
+	env := map[string]string{
+		envAPIKeyID:     lunotest.DefaultKeyID,
+		envAPIKeySecret: lunotest.DefaultKeySecret,
+		envBaseURL:      srv.URL(),
+	}
+	var stdout bytes.Buffer
+	err := run(args, func(k string) string { return env[k] }, &stdout, ioutil.Discard)
+	return stdout.String(), err
+}
+
+func TestTicker(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1000.5"), dec(t, "1"))
+
+	out, err := runCLI(t, srv, "ticker", "-pair", "XBTZAR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "ask") || !strings.Contains(out, "1000.5") {
+		t.Errorf("Expected ask of 1000.5, got:\n%s", out)
+	}
+}
+
+func TestPlaceAndListOrders(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+	srv.Fund("ZAR", dec(t, "1000"))
+
+	out, err := runCLI(t, srv, "-output", "json", "orders", "place",
+		"-pair", "XBTZAR", "-type", "BID", "-price", "100", "-volume", "0.5",
+		"-post_only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res luno.PostLimitOrderResponse
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = runCLI(t, srv, "-output", "csv", "list-orders", "-state", "PENDING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], res.OrderId) {
+		t.Errorf("Expected one order %s, got:\n%s", res.OrderId, out)
+	}
+}
+
+func TestOrderBookTable(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1000"), dec(t, "1"))
+	srv.AddOrder("XBTZAR", luno.OrderTypeBid, dec(t, "900"), dec(t, "2"))
+
+	out, err := runCLI(t, srv, "book", "-pair", "XBTZAR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got:\n%s", out)
+	}
+	if f := strings.Fields(lines[0]); strings.Join(f, " ") != "LIST PRICE VOLUME" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+	srv.Fund("ZAR", dec(t, "1000"))
+
+	out, err := runCLI(t, srv, "-dry_run", "withdrawals", "create",
+		"-type", "ZAR_EFT", "-amount", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"POST " + srv.URL() + "/api/1/withdrawals",
+		"Authorization: " + luno.Redacted, "amount=100"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected output to contain %q, got:\n%s", exp, out)
+		}
+	}
+	if strings.Contains(out, lunotest.DefaultKeySecret) {
+		t.Errorf("Expected credentials to be redacted")
+	}
+
+	res, err := srv.Client().ListWithdrawals(context.Background(), &luno.ListWithdrawalsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Withdrawals) != 0 {
+		t.Errorf("Expected no withdrawals, got %d", len(res.Withdrawals))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	type testCase struct {
+		args []string
+		exp  string
+	}
+	tests := []testCase{
+		{args: []string{"nope"}, exp: `unknown command "nope"`},
+		{args: []string{"orders", "place", "-pair", "XBTZAR"}, exp: "missing required flags -price, -type, -volume"},
+		{args: []string{"-output", "xml", "tickers"}, exp: `unknown output format "xml"`},
+		{args: []string{"orders", "get", "-id", "missing"}, exp: "ErrOrderNotFound"},
+	}
+	for _, test := range tests {
+		_, err := runCLI(t, srv, test.args...)
+		if err == nil || !strings.Contains(err.Error(), test.exp) {
+			t.Errorf("%v: expected error %q, got %v", test.args, test.exp, err)
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "luno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	b, _ := json.Marshal(config{
+		APIKeyID:     lunotest.DefaultKeyID,
+		APIKeySecret: lunotest.DefaultKeySecret,
+		BaseURL:      srv.URL(),
+	})
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{envConfig: path}
+	err = run([]string{"balances"}, func(k string) string { return env[k] },
+		ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+
+	// Flags take precedence over the config file.
+	err = run([]string{"-api_key_secret", "wrong", "balances"},
+		func(k string) string { return env[k] }, ioutil.Discard, ioutil.Discard)
+	if !strings.Contains(err.Error(), "ErrUnauthorised") {
+		t.Errorf("Expected ErrUnauthorised, got %v", err)
+	}
+}
+
+func TestEveryMethodHasCommand(t *testing.T) {
+	if len(commands) != 26 {
+		t.Errorf("Expected 26 commands, got %d", len(commands))
+	}
+	for alias, name := range aliases {
+		if _, _, err := findCommand([]string{name}); err != nil {
+			t.Errorf("Alias %q refers to unknown command %q", alias, name)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// writeResult writes a response in the given format.
+func writeResult(w io.Writer, format string, res interface{}) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case formatTable:
+		header, rows := tabulate(res)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, r := range rows {
+			fmt.Fprintln(tw, strings.Join(r, "\t"))
+		}
+		return tw.Flush()
+	case formatCSV:
+		header, rows := tabulate(res)
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// tabulate converts a response struct to rows. Lists of structs, like the
+// orders of ListOrdersResponse, become one row per element. If a response has
+// several lists of the same type, like the bids and asks of an order book,
+// the rows are prefixed with the name of the list. Any other response becomes
+// one row per field.
+func tabulate(res interface{}) ([]string, [][]string) {
+	v := reflect.Indirect(reflect.ValueOf(res))
+
+	var lists []int
+	for i := 0; i < v.NumField(); i++ {
+		t := v.Field(i).Type()
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct {
+			lists = append(lists, i)
+		}
+	}
+	if len(lists) > 0 && sameElem(v, lists) {
+		elem := v.Field(lists[0]).Type().Elem()
+		header := jsonNames(elem)
+		if len(lists) > 1 {
+			header = append([]string{"list"}, header...)
+		}
+
+		var rows [][]string
+		for _, i := range lists {
+			list := jsonName(v.Type().Field(i))
+			for j := 0; j < v.Field(i).Len(); j++ {
+				var row []string
+				if len(lists) > 1 {
+					row = append(row, list)
+				}
+				rows = append(rows, append(row, cells(v.Field(i).Index(j))...))
+			}
+		}
+		return header, rows
+	}
+
+	var rows [][]string
+	for i := 0; i < v.NumField(); i++ {
+		rows = append(rows, []string{jsonName(v.Type().Field(i)), cell(v.Field(i))})
+	}
+	return []string{"field", "value"}, rows
+}
+
+func sameElem(v reflect.Value, fields []int) bool {
+	for _, i := range fields {
+		if v.Field(i).Type().Elem() != v.Field(fields[0]).Type().Elem() {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, jsonName(t.Field(i)))
+	}
+	return names
+}
+
+func cells(v reflect.Value) []string {
+	var l []string
+	for i := 0; i < v.NumField(); i++ {
+		l = append(l, cell(v.Field(i)))
+	}
+	return l
+}
+
+// cell formats a value for a table or CSV cell. Amounts keep their full
+// precision and timestamps are formatted as RFC 3339 in UTC.
+func cell(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case decimal.Decimal:
+		return x.String()
+	case luno.Time:
+		t := time.Time(x)
+		if t.IsZero() || t.Unix() == 0 {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err.Error()
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -123,6 +123,11 @@ func (d Decimal) ToScale(scale int) Decimal {
 	return Decimal{i: o, scale: scale}
 }
 
+// Scale returns the number of digits after the decimal point in d.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
 // Sign returns -1 if d is negative, 1 if d is positive and 0 if d is zero.
 func (d Decimal) Sign() int {
 	return bigIntDefault(d.i).Sign()
@@ -201,6 +201,40 @@ func TestDecimalString(t *testing.T) {
 	}
 }
 
+func TestDecimalScale(t *testing.T) {
+	type testCase struct {
+		s   string
+		exp int
+	}
+
+	testCases := []testCase{
+		testCase{
+			s:   "0",
+			exp: 0,
+		},
+		testCase{
+			s:   "1.50",
+			exp: 2,
+		},
+		testCase{
+			s:   "-0.00000001",
+			exp: 8,
+		},
+	}
+
+	for _, test := range testCases {
+		d, err := decimal.NewFromString(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act := d.Scale()
+		if act != test.exp {
+			t.Errorf("Expected the scale of %s to be %d, got %d",
+				test.s, test.exp, act)
+		}
+	}
+}
+
 func TestDecimalSign(t *testing.T) {
 	type testCase struct {
 		d   decimal.Decimal
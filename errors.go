@@ -0,0 +1,105 @@
+package luno
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error is a Luno API error.
+//
+// All errors returned by the API are of this type and can be inspected with
+// errors.As. Well-known error codes can be matched against the sentinel
+// values below with errors.Is.
+type Error struct {
+	// Code can be used to identify errors even if the error message is
+	// localised.
+	Code string `json:"error_code"`
+
+	// Message may be localised for authenticated API calls.
+	Message string `json:"error"`
+
+	// HTTPStatus is the HTTP status code of the response.
+	HTTPStatus int `json:"-"`
+
+	// Method and Path identify the API call that failed, e.g. "POST" and
+	// "/api/1/postorder".
+	Method string `json:"-"`
+	Path   string `json:"-"`
+
+	// RetryAfter is the delay requested by the server before the call may be
+	// retried. It is zero if the server did not provide a hint.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return "luno: " + e.Message
+	}
+	return fmt.Sprintf("luno: %s (%s)", e.Message, e.Code)
+}
+
+// Is reports whether target is an *Error with the same code. It allows the
+// sentinel errors below to be used with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code != "" && t.Code == e.Code
+}
+
+// Sentinel errors for well-known error codes. They only carry a code and
+// should be used as targets for errors.Is, never returned directly.
+var (
+	ErrAccountNotFound     = &Error{Code: "ErrAccountNotFound"}
+	ErrAmountTooSmall      = &Error{Code: "ErrAmountTooSmall"}
+	ErrInsufficientBalance = &Error{Code: "ErrInsufficientBalance"}
+	ErrInvalidArguments    = &Error{Code: "ErrInvalidArguments"}
+	ErrInvalidPair         = &Error{Code: "ErrInvalidPair"}
+	ErrOrderNotFound       = &Error{Code: "ErrOrderNotFound"}
+	ErrTooManyRequests     = &Error{Code: "ErrTooManyRequests"}
+	ErrUnauthorised        = &Error{Code: "ErrUnauthorised"}
+)
+
+// newHTTPError returns an *Error for a response with the given status. The code
+// is filled in for statuses that map to a well-known error.
+func newHTTPError(method, path string, res *http.Response, e Error) *Error {
+	e.HTTPStatus = res.StatusCode
+	e.Method = method
+	e.Path = path
+	e.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+
+	if e.Code == "" {
+		switch res.StatusCode {
+		case http.StatusTooManyRequests:
+			e.Code = ErrTooManyRequests.Code
+		case http.StatusUnauthorized, http.StatusForbidden:
+			e.Code = ErrUnauthorised.Code
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(res.StatusCode)
+	}
+	return &e
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may be
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(s)
+	if err != nil || t.Before(now) {
+		return 0
+	}
+	return t.Sub(now)
+}
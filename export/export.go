@@ -0,0 +1,347 @@
+// Package export builds reconciled account statements from the Luno API and
+// writes them as CSV, JSON Lines or OFX.
+//
+// A statement is built from the account's transactions, which are checked for
+// completeness by row index and for consistency of the running balances.
+// Transactions are linked to the user trades and withdrawals that caused them
+// where possible.
+//
+// Example:
+//
+//	l, err := export.Build(ctx, cl, accountID, export.Options{
+//		Markets: []export.Market{{Pair: "XBTZAR", Base: "XBT", Counter: "ZAR"}},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = l.Write(os.Stdout, export.CSV)
+package export
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// matchWindow is how far apart the timestamps of a transaction and the trade
+// or withdrawal that caused it may be.
+const matchWindow = time.Second
+
+// Kind classifies ledger entries.
+type Kind string
+
+const (
+	// KindTrade is a settlement of a user trade.
+	KindTrade Kind = "TRADE"
+	// KindWithdrawal is a debit for a withdrawal.
+	KindWithdrawal Kind = "WITHDRAWAL"
+	// KindReservation only changes the available balance, e.g. when funds
+	// are reserved for an order or released again.
+	KindReservation Kind = "RESERVATION"
+	// KindCredit and KindDebit are other movements of the balance, e.g.
+	// deposits, sends and fees.
+	KindCredit Kind = "CREDIT"
+	KindDebit  Kind = "DEBIT"
+)
+
+// Entry is a single transaction in a ledger.
+type Entry struct {
+	Row            int64           `json:"row_index"`
+	Timestamp      time.Time       `json:"timestamp"`
+	Kind           Kind            `json:"kind"`
+	Description    string          `json:"description"`
+	BalanceDelta   decimal.Decimal `json:"balance_delta"`
+	AvailableDelta decimal.Decimal `json:"available_delta"`
+	Balance        decimal.Decimal `json:"balance"`
+	Available      decimal.Decimal `json:"available"`
+
+	// Trade is the user trade settled by the entry, if any.
+	Trade *luno.Trade `json:"trade,omitempty"`
+	// Withdrawal is the withdrawal debited by the entry, if any.
+	Withdrawal *luno.Withdrawal `json:"withdrawal,omitempty"`
+}
+
+// Ledger is the reconciled transaction history of an account.
+type Ledger struct {
+	AccountID string
+	Currency  string
+	Name      string
+
+	// Start and End are the times of the first and last entries.
+	Start time.Time
+	End   time.Time
+
+	// OpeningBalance and OpeningAvailable are the balances before the
+	// first entry.
+	OpeningBalance   decimal.Decimal
+	OpeningAvailable decimal.Decimal
+
+	Entries []Entry
+}
+
+// ClosingBalance returns the balance after the last entry.
+func (l *Ledger) ClosingBalance() decimal.Decimal {
+	if len(l.Entries) == 0 {
+		return l.OpeningBalance
+	}
+	return l.Entries[len(l.Entries)-1].Balance
+}
+
+// ClosingAvailable returns the available balance after the last entry.
+func (l *Ledger) ClosingAvailable() decimal.Decimal {
+	if len(l.Entries) == 0 {
+		return l.OpeningAvailable
+	}
+	return l.Entries[len(l.Entries)-1].Available
+}
+
+// Options configures Build.
+type Options struct {
+	// Since and Until limit the ledger to transactions in the time range
+	// [Since, Until). Zero values leave the range open.
+	Since time.Time
+	Until time.Time
+
+	// Markets are the markets to fetch user trades from. Only trades in
+	// these markets are linked to entries.
+	Markets []Market
+}
+
+// Market is a currency pair, e.g. "XBTZAR", and its base and counter
+// currencies, e.g. "XBT" and "ZAR".
+type Market struct {
+	Pair    string
+	Base    string
+	Counter string
+}
+
+func (m Market) validate() error {
+	if m.Pair == "" || m.Base == "" || m.Counter == "" || m.Base == m.Counter {
+		return fmt.Errorf("export: invalid market %q with currencies %q and %q",
+			m.Pair, m.Base, m.Counter)
+	}
+	return nil
+}
+
+// ReconcileError is returned by Build when an entry's balances don't follow
+// from the previous entry's.
+type ReconcileError struct {
+	Row      int64
+	Field    string
+	Expected decimal.Decimal
+	Got      decimal.Decimal
+}
+
+func (e *ReconcileError) Error() string {
+	return fmt.Sprintf("export: row %d: expected %s %s, got %s",
+		e.Row, e.Field, e.Expected, e.Got)
+}
+
+// Build fetches the transactions of an account and returns them as a
+// reconciled ledger. It fails with luno.ErrMissingRows if the history has
+// gaps and with a *ReconcileError if the running balances don't add up.
+func Build(ctx context.Context, cl *luno.Client, accountID string, opts Options) (*Ledger, error) {
+	markets := make(map[string]Market)
+	for _, m := range opts.Markets {
+		if err := m.validate(); err != nil {
+			return nil, err
+		}
+		markets[m.Pair] = m
+	}
+
+	l, err := newLedger(ctx, cl, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	first := true
+	it := cl.IterTransactions(ctx, accountID, luno.IterOptions{
+		Since: opts.Since,
+		Until: opts.Until,
+	})
+	for it.Next() {
+		t := it.Transaction()
+		if first {
+			l.OpeningBalance = t.Balance.Sub(t.BalanceDelta)
+			l.OpeningAvailable = t.Available.Sub(t.AvailableDelta)
+			l.Start = time.Time(t.Timestamp).UTC()
+			first = false
+		}
+		if err := l.append(t); err != nil {
+			return nil, err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		l.OpeningBalance = decimal.Zero()
+		l.OpeningAvailable = decimal.Zero()
+		return l, nil
+	}
+	l.End = l.Entries[len(l.Entries)-1].Timestamp
+
+	trades, err := userTrades(ctx, cl, l.Currency, opts)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := withdrawals(ctx, cl, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.link(markets, trades, ws)
+	return l, nil
+}
+
+func newLedger(ctx context.Context, cl *luno.Client, accountID string) (*Ledger, error) {
+	res, err := cl.GetBalances(ctx, &luno.GetBalancesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range res.Balance {
+		if b.AccountId == accountID {
+			return &Ledger{AccountID: b.AccountId, Currency: b.Asset, Name: b.Name}, nil
+		}
+	}
+	return nil, luno.ErrAccountNotFound
+}
+
+// append checks the running balances and adds t to the ledger.
+func (l *Ledger) append(t luno.Transaction) error {
+	expBalance := l.ClosingBalance().Add(t.BalanceDelta)
+	if expBalance.Cmp(t.Balance) != 0 {
+		return &ReconcileError{t.RowIndex, "balance", expBalance, t.Balance}
+	}
+	expAvailable := l.ClosingAvailable().Add(t.AvailableDelta)
+	if expAvailable.Cmp(t.Available) != 0 {
+		return &ReconcileError{t.RowIndex, "available", expAvailable, t.Available}
+	}
+
+	kind := KindReservation
+	switch t.BalanceDelta.Sign() {
+	case 1:
+		kind = KindCredit
+	case -1:
+		kind = KindDebit
+	}
+	l.Entries = append(l.Entries, Entry{
+		Row:            t.RowIndex,
+		Timestamp:      time.Time(t.Timestamp).UTC(),
+		Kind:           kind,
+		Description:    t.Description,
+		BalanceDelta:   t.BalanceDelta,
+		AvailableDelta: t.AvailableDelta,
+		Balance:        t.Balance,
+		Available:      t.Available,
+	})
+	return nil
+}
+
+// userTrades returns the user's trades in the markets of opts which involve
+// currency.
+func userTrades(ctx context.Context, cl *luno.Client, currency string,
+	opts Options) ([]luno.Trade, error) {
+
+	var trades []luno.Trade
+	for _, m := range opts.Markets {
+		if m.Base != currency && m.Counter != currency {
+			continue
+		}
+		iterOpts := luno.IterOptions{Until: opts.Until}
+		if !opts.Since.IsZero() {
+			iterOpts.Since = opts.Since.Add(-matchWindow)
+		}
+		it := cl.IterUserTrades(ctx, m.Pair, iterOpts)
+		for it.Next() {
+			t := it.Trade()
+			if t.Pair == "" {
+				t.Pair = m.Pair
+			}
+			trades = append(trades, t)
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return trades, nil
+}
+
+// withdrawals returns the user's withdrawals in the time range of opts.
+func withdrawals(ctx context.Context, cl *luno.Client, opts Options) ([]luno.Withdrawal, error) {
+	iterOpts := luno.IterOptions{Until: opts.Until}
+	if !opts.Since.IsZero() {
+		iterOpts.Since = opts.Since.Add(-matchWindow)
+	}
+	var ws []luno.Withdrawal
+	it := cl.IterWithdrawals(ctx, iterOpts)
+	for it.Next() {
+		ws = append(ws, it.Withdrawal())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// link attaches each trade and withdrawal to the closest entry in time which
+// moves the matching amount. markets are the trades' markets by pair.
+func (l *Ledger) link(markets map[string]Market, trades []luno.Trade,
+	withdrawals []luno.Withdrawal) {
+
+	for i := range trades {
+		t := &trades[i]
+		amounts := []decimal.Decimal{t.Counter, t.Counter.Sub(t.FeeCounter)}
+		if markets[t.Pair].Base == l.Currency {
+			amounts = []decimal.Decimal{t.Base, t.Base.Sub(t.FeeBase)}
+		}
+		if e := l.closest(time.Time(t.Timestamp), amounts); e != nil {
+			e.Kind = KindTrade
+			e.Trade = t
+		}
+	}
+	for i := range withdrawals {
+		w := &withdrawals[i]
+		if w.Currency != l.Currency {
+			continue
+		}
+		amounts := []decimal.Decimal{w.Amount, w.Amount.Add(w.Fee)}
+		if e := l.closest(time.Time(w.CreatedAt), amounts); e != nil {
+			e.Kind = KindWithdrawal
+			e.Withdrawal = w
+		}
+	}
+}
+
+// closest returns the unlinked entry closest to ts whose balance changes by
+// one of amounts, or nil if there isn't one within matchWindow.
+func (l *Ledger) closest(ts time.Time, amounts []decimal.Decimal) *Entry {
+	var best *Entry
+	var bestDiff time.Duration
+	for i := range l.Entries {
+		e := &l.Entries[i]
+		if e.Trade != nil || e.Withdrawal != nil || e.BalanceDelta.Sign() == 0 {
+			continue
+		}
+		diff := e.Timestamp.Sub(ts)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > matchWindow || (best != nil && diff >= bestDiff) {
+			continue
+		}
+		abs := e.BalanceDelta
+		if abs.Sign() < 0 {
+			abs = abs.Neg()
+		}
+		for _, a := range amounts {
+			if abs.Cmp(a) == 0 {
+				best, bestDiff = e, diff
+				break
+			}
+		}
+	}
+	return best
+}
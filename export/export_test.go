@@ -0,0 +1,226 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"github.com/luno/luno-go/export"
+	"github.com/luno/luno-go/lunotest"
+)
+
+func dec(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// newLedger returns a ledger of the ZAR account after a deposit, a trade and
+// a withdrawal.
+func newLedger(t *testing.T) *export.Ledger {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	now := time.Unix(1500000000, 0)
+	srv.SetClock(func() time.Time { return now })
+
+	ctx := context.Background()
+	cl := srv.Client()
+	id := srv.Fund("ZAR", dec(t, "1000.000000000000000001"))
+
+	now = now.Add(time.Minute)
+	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "100"), dec(t, "1"))
+	_, err := cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{
+		Pair:   "XBTZAR",
+		Type:   luno.OrderTypeBid,
+		Price:  dec(t, "100"),
+		Volume: dec(t, "0.5"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	_, err = cl.CreateWithdrawal(ctx, &luno.CreateWithdrawalRequest{
+		Type:   "ZAR_EFT",
+		Amount: dec(t, "300"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := export.Build(ctx, cl, id, export.Options{
+		Markets: []export.Market{{Pair: "XBTZAR", Base: "XBT", Counter: "ZAR"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestBuild(t *testing.T) {
+	l := newLedger(t)
+
+	if l.Currency != "ZAR" {
+		t.Errorf("Expected ZAR, got %s", l.Currency)
+	}
+	if l.OpeningBalance.Sign() != 0 {
+		t.Errorf("Expected opening balance 0, got %s", l.OpeningBalance)
+	}
+	if exp := "650.000000000000000001"; l.ClosingBalance().String() != exp {
+		t.Errorf("Expected closing balance %s, got %s", exp, l.ClosingBalance())
+	}
+
+	var kinds []string
+	for _, e := range l.Entries {
+		kinds = append(kinds, string(e.Kind))
+	}
+	// Deposit, reservation for the bid, trade settlement and withdrawal.
+	exp := "CREDIT RESERVATION TRADE WITHDRAWAL"
+	if act := strings.Join(kinds, " "); act != exp {
+		t.Errorf("Expected entries %s, got %s", exp, act)
+	}
+	if tr := l.Entries[2].Trade; tr == nil || tr.Counter.Cmp(dec(t, "50")) != 0 {
+		t.Errorf("Expected trade for 50, got %+v", tr)
+	}
+	if l.Start.Location() != time.UTC || l.End.Location() != time.UTC {
+		t.Errorf("Expected UTC start and end, got %v and %v", l.Start, l.End)
+	}
+}
+
+func TestBuildOverlappingCurrencies(t *testing.T) {
+	// The counter currency is a prefix of the base currency.
+	m := lunotest.Market{Pair: "USDCUSD", Base: "USDC", Counter: "USD"}
+	srv, err := lunotest.NewMarketServer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	id := srv.Fund("USD", dec(t, "100"))
+	srv.AddOrder(m.Pair, luno.OrderTypeAsk, dec(t, "2"), dec(t, "10"))
+	_, err = cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{
+		Pair:   m.Pair,
+		Type:   luno.OrderTypeBid,
+		Price:  dec(t, "2"),
+		Volume: dec(t, "10"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := export.Build(ctx, cl, id, export.Options{
+		Markets: []export.Market{{Pair: m.Pair, Base: m.Base, Counter: m.Counter}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := l.Entries[len(l.Entries)-1]; e.Kind != export.KindTrade {
+		t.Errorf("Expected the trade to be linked, got %+v", e)
+	}
+}
+
+func TestBuildInvalidMarket(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+	id := srv.Fund("ZAR", dec(t, "1"))
+
+	_, err := export.Build(context.Background(), srv.Client(), id, export.Options{
+		Markets: []export.Market{{Pair: "XBTZAR", Base: "XBT"}},
+	})
+	if err == nil {
+		t.Errorf("Expected error for market without counter currency")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	l := newLedger(t)
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf, export.CSV); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != len(l.Entries)+1 {
+		t.Fatalf("Expected %d records, got %d", len(l.Entries)+1, len(recs))
+	}
+	if recs[1][6] != "1000.000000000000000001" {
+		t.Errorf("Expected exact amount, got %s", recs[1][6])
+	}
+	if recs[3][10] != "XBTZAR" {
+		t.Errorf("Expected trade pair, got %q", recs[3][10])
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	l := newLedger(t)
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf, export.JSONL); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(l.Entries) {
+		t.Fatalf("Expected %d lines, got %d", len(l.Entries), len(lines))
+	}
+	var e struct {
+		AccountID    string          `json:"account_id"`
+		BalanceDelta decimal.Decimal `json:"balance_delta"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.AccountID != l.AccountID || e.BalanceDelta.String() != "1000.000000000000000001" {
+		t.Errorf("Unexpected first line %s", lines[0])
+	}
+}
+
+func TestWriteOFX(t *testing.T) {
+	l := newLedger(t)
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf, export.OFX); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Trns []struct {
+			Type   string `xml:"TRNTYPE"`
+			Amount string `xml:"TRNAMT"`
+		} `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>STMTTRN"`
+		Balance string `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>LEDGERBAL>BALAMT"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	// The reservation is left out.
+	if len(doc.Trns) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(doc.Trns))
+	}
+	if doc.Trns[2].Type != "DEBIT" || doc.Trns[2].Amount != "-300" {
+		t.Errorf("Expected debit of -300, got %+v", doc.Trns[2])
+	}
+	if doc.Balance != "650.000000000000000001" {
+		t.Errorf("Expected balance 650.000000000000000001, got %s", doc.Balance)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var l export.Ledger
+	if err := l.Write(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format is an output format for ledgers.
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+	OFX   Format = "ofx"
+)
+
+// Write writes the ledger to w in the given format.
+func (l *Ledger) Write(w io.Writer, f Format) error {
+	switch f {
+	case CSV:
+		return l.WriteCSV(w)
+	case JSONL:
+		return l.WriteJSONL(w)
+	case OFX:
+		return l.WriteOFX(w)
+	default:
+		return fmt.Errorf("export: unknown format %q", f)
+	}
+}
+
+var csvHeader = []string{
+	"row_index", "timestamp", "account_id", "currency", "kind",
+	"description", "balance_delta", "available_delta", "balance",
+	"available", "pair", "order_id", "price", "base", "counter",
+	"fee_base", "fee_counter", "withdrawal_id", "withdrawal_status",
+}
+
+// WriteCSV writes the ledger as CSV with a header row. Amounts are written in
+// full precision and timestamps in RFC 3339 format.
+func (l *Ledger) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range l.Entries {
+		rec := []string{
+			strconv.FormatInt(e.Row, 10),
+			e.Timestamp.Format(time.RFC3339Nano),
+			l.AccountID,
+			l.Currency,
+			string(e.Kind),
+			e.Description,
+			e.BalanceDelta.String(),
+			e.AvailableDelta.String(),
+			e.Balance.String(),
+			e.Available.String(),
+		}
+		if t := e.Trade; t != nil {
+			rec = append(rec, t.Pair, t.OrderId, t.Price.String(),
+				t.Base.String(), t.Counter.String(),
+				t.FeeBase.String(), t.FeeCounter.String())
+		} else {
+			rec = append(rec, "", "", "", "", "", "", "")
+		}
+		if wd := e.Withdrawal; wd != nil {
+			rec = append(rec, wd.Id, wd.Status)
+		} else {
+			rec = append(rec, "", "")
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonEntry struct {
+	AccountID string `json:"account_id"`
+	Currency  string `json:"currency"`
+	Entry
+}
+
+// WriteJSONL writes the ledger as JSON Lines, one entry per line. Amounts are
+// encoded as strings so that no precision is lost.
+func (l *Ledger) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range l.Entries {
+		if err := enc.Encode(jsonEntry{l.AccountID, l.Currency, e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ofxHeader is the processing instruction of an OFX 2.2 document.
+const ofxHeader = `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+type ofxDoc struct {
+	XMLName xml.Name  `xml:"OFX"`
+	Signon  ofxSignon `xml:"SIGNONMSGSRSV1>SONRS"`
+	Stmt    ofxStmtRs `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignon struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DTServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStmtRs struct {
+	TrnUID    string    `xml:"TRNUID"`
+	Status    ofxStatus `xml:"STATUS"`
+	CurDef    string    `xml:"STMTRS>CURDEF"`
+	BankID    string    `xml:"STMTRS>BANKACCTFROM>BANKID"`
+	AcctID    string    `xml:"STMTRS>BANKACCTFROM>ACCTID"`
+	AcctType  string    `xml:"STMTRS>BANKACCTFROM>ACCTTYPE"`
+	DTStart   string    `xml:"STMTRS>BANKTRANLIST>DTSTART"`
+	DTEnd     string    `xml:"STMTRS>BANKTRANLIST>DTEND"`
+	Trns      []ofxTrn  `xml:"STMTRS>BANKTRANLIST>STMTTRN"`
+	LedgerAmt string    `xml:"STMTRS>LEDGERBAL>BALAMT"`
+	LedgerAt  string    `xml:"STMTRS>LEDGERBAL>DTASOF"`
+	AvailAmt  string    `xml:"STMTRS>AVAILBAL>BALAMT"`
+	AvailAt   string    `xml:"STMTRS>AVAILBAL>DTASOF"`
+}
+
+type ofxTrn struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FITID    string `xml:"FITID"`
+	Name     string `xml:"NAME,omitempty"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+// ofxTime formats t as an OFX date-time in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+// ofxName truncates s to the 32 characters allowed in NAME.
+func ofxName(s string) string {
+	r := []rune(s)
+	if len(r) > 32 {
+		r = r[:32]
+	}
+	return string(r)
+}
+
+// WriteOFX writes the ledger as an OFX 2.2 bank statement. Reservations, which
+// don't change the balance, are left out. Transaction IDs are derived from the
+// account ID and row index, so they're stable across exports.
+func (l *Ledger) WriteOFX(w io.Writer) error {
+	end := l.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	doc := ofxDoc{
+		Signon: ofxSignon{
+			Status:   ofxStatus{Code: 0, Severity: "INFO"},
+			DTServer: ofxTime(time.Now()),
+			Language: "ENG",
+		},
+		Stmt: ofxStmtRs{
+			TrnUID:    "0",
+			Status:    ofxStatus{Code: 0, Severity: "INFO"},
+			CurDef:    l.Currency,
+			BankID:    "LUNO",
+			AcctID:    l.AccountID,
+			AcctType:  "CHECKING",
+			DTStart:   ofxTime(l.Start),
+			DTEnd:     ofxTime(end),
+			LedgerAmt: l.ClosingBalance().String(),
+			LedgerAt:  ofxTime(end),
+			AvailAmt:  l.ClosingAvailable().String(),
+			AvailAt:   ofxTime(end),
+		},
+	}
+	for _, e := range l.Entries {
+		if e.Kind == KindReservation {
+			continue
+		}
+		typ := "CREDIT"
+		if e.BalanceDelta.Sign() < 0 {
+			typ = "DEBIT"
+		}
+		doc.Stmt.Trns = append(doc.Stmt.Trns, ofxTrn{
+			TrnType:  typ,
+			DTPosted: ofxTime(e.Timestamp),
+			TrnAmt:   e.BalanceDelta.String(),
+			FITID:    l.AccountID + "-" + strconv.FormatInt(e.Row, 10),
+			Name:     ofxName(e.Description),
+			Memo:     e.Description,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header+ofxHeader); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
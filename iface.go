@@ -0,0 +1,64 @@
+// Code generated by internal/apigen. DO NOT EDIT.
+
+package luno
+
+import "context"
+
+// API is the interface implemented by Client. Depend on it instead of *Client
+// to substitute a fake, e.g. lunomock.Client, in tests.
+type API interface {
+	// CancelWithdrawal makes a call to DELETE /api/1/withdrawals/{id}.
+	CancelWithdrawal(ctx context.Context, req *CancelWithdrawalRequest) (*CancelWithdrawalResponse, error)
+	// CreateAccount makes a call to POST /api/1/accounts.
+	CreateAccount(ctx context.Context, req *CreateAccountRequest) (*CreateAccountResponse, error)
+	// CreateFundingAddress makes a call to POST /api/1/funding_address.
+	CreateFundingAddress(ctx context.Context, req *CreateFundingAddressRequest) (*CreateFundingAddressResponse, error)
+	// CreateQuote makes a call to POST /api/1/quotes.
+	CreateQuote(ctx context.Context, req *CreateQuoteRequest) (*CreateQuoteResponse, error)
+	// CreateWithdrawal makes a call to POST /api/1/withdrawals.
+	CreateWithdrawal(ctx context.Context, req *CreateWithdrawalRequest) (*CreateWithdrawalResponse, error)
+	// DiscardQuote makes a call to DELETE /api/1/quotes/{id}.
+	DiscardQuote(ctx context.Context, req *DiscardQuoteRequest) (*DiscardQuoteResponse, error)
+	// ExerciseQuote makes a call to PUT /api/1/quotes/{id}.
+	ExerciseQuote(ctx context.Context, req *ExerciseQuoteRequest) (*ExerciseQuoteResponse, error)
+	// GetBalances makes a call to GET /api/1/balance.
+	GetBalances(ctx context.Context, req *GetBalancesRequest) (*GetBalancesResponse, error)
+	// GetFeeInfo makes a call to GET /api/1/fee_info.
+	GetFeeInfo(ctx context.Context, req *GetFeeInfoRequest) (*GetFeeInfoResponse, error)
+	// GetFundingAddress makes a call to GET /api/1/funding_address.
+	GetFundingAddress(ctx context.Context, req *GetFundingAddressRequest) (*GetFundingAddressResponse, error)
+	// GetOrder makes a call to GET /api/1/orders/{id}.
+	GetOrder(ctx context.Context, req *GetOrderRequest) (*GetOrderResponse, error)
+	// GetOrderBook makes a call to GET /api/1/orderbook.
+	GetOrderBook(ctx context.Context, req *GetOrderBookRequest) (*GetOrderBookResponse, error)
+	// GetQuote makes a call to GET /api/1/quotes/{id}.
+	GetQuote(ctx context.Context, req *GetQuoteRequest) (*GetQuoteResponse, error)
+	// GetTicker makes a call to GET /api/1/ticker.
+	GetTicker(ctx context.Context, req *GetTickerRequest) (*GetTickerResponse, error)
+	// GetTickers makes a call to GET /api/1/tickers.
+	GetTickers(ctx context.Context, req *GetTickersRequest) (*GetTickersResponse, error)
+	// GetWithdrawal makes a call to GET /api/1/withdrawals/{id}.
+	GetWithdrawal(ctx context.Context, req *GetWithdrawalRequest) (*GetWithdrawalResponse, error)
+	// ListOrders makes a call to GET /api/1/listorders.
+	ListOrders(ctx context.Context, req *ListOrdersRequest) (*ListOrdersResponse, error)
+	// ListPendingTransactions makes a call to GET /api/1/accounts/{id}/pending.
+	ListPendingTransactions(ctx context.Context, req *ListPendingTransactionsRequest) (*ListPendingTransactionsResponse, error)
+	// ListTrades makes a call to GET /api/1/trades.
+	ListTrades(ctx context.Context, req *ListTradesRequest) (*ListTradesResponse, error)
+	// ListTransactions makes a call to GET /api/1/accounts/{id}/transactions.
+	ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// ListUserTrades makes a call to GET /api/1/listtrades.
+	ListUserTrades(ctx context.Context, req *ListUserTradesRequest) (*ListUserTradesResponse, error)
+	// ListWithdrawals makes a call to GET /api/1/withdrawals.
+	ListWithdrawals(ctx context.Context, req *ListWithdrawalsRequest) (*ListWithdrawalsResponse, error)
+	// PostLimitOrder makes a call to POST /api/1/postorder.
+	PostLimitOrder(ctx context.Context, req *PostLimitOrderRequest) (*PostLimitOrderResponse, error)
+	// PostMarketOrder makes a call to POST /api/1/marketorder.
+	PostMarketOrder(ctx context.Context, req *PostMarketOrderRequest) (*PostMarketOrderResponse, error)
+	// Send makes a call to POST /api/1/send.
+	Send(ctx context.Context, req *SendRequest) (*SendResponse, error)
+	// StopOrder makes a call to POST /api/1/stoporder.
+	StopOrder(ctx context.Context, req *StopOrderRequest) (*StopOrderResponse, error)
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,142 @@
+package luno
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// FillEstimate is the expected result of a market order, found by walking an
+// order book from the best price.
+type FillEstimate struct {
+	// Base and Counter are the volumes which would be traded.
+	Base    decimal.Decimal
+	Counter decimal.Decimal
+
+	// VWAP is the volume-weighted average price, Counter/Base, truncated to 8
+	// more decimal places than the prices. It is zero if nothing would be
+	// filled.
+	VWAP decimal.Decimal
+
+	// WorstPrice is the price of the last level the order would reach.
+	WorstPrice decimal.Decimal
+
+	// Mid is the mid price of the book before the order, or zero if either
+	// side of the book is empty.
+	Mid decimal.Decimal
+
+	// Slippage is how much worse VWAP is than Mid, i.e. VWAP-Mid for a buy
+	// and Mid-VWAP for a sell. It is zero if Mid or VWAP is zero.
+	Slippage decimal.Decimal
+
+	// Unfilled is the part of the requested base or counter volume which
+	// would not be traded, because the book is too thin or, for a counter
+	// volume, because it is less than the smallest base volume at the last
+	// price.
+	Unfilled decimal.Decimal
+}
+
+// vwapDigits is the number of decimal places VWAP is given to beyond those
+// of the book's prices.
+const vwapDigits = 8
+
+// half is 0.5, for computing mid prices exactly.
+var half = decimal.New(big.NewInt(5), 1)
+
+// EstimateMarketOrder simulates a market order against an order book whose
+// bids are sorted by price descending and asks by price ascending, as
+// returned by GetOrderBook. typ is OrderTypeBuy or OrderTypeSell and exactly
+// one of base or counter must be positive, like in PostMarketOrderRequest.
+//
+// Partial fills of a level by counter volume are truncated to the scale of
+// the book's volumes.
+func EstimateMarketOrder(bids, asks []OrderBookEntry, typ OrderType,
+	base, counter decimal.Decimal) (FillEstimate, error) {
+
+	if (base.Sign() > 0) == (counter.Sign() > 0) {
+		return FillEstimate{}, errors.New("luno: exactly one of base and counter volume must be positive")
+	}
+
+	var levels []OrderBookEntry
+	switch typ {
+	case OrderTypeBuy:
+		levels = asks
+	case OrderTypeSell:
+		levels = bids
+	default:
+		return FillEstimate{}, errors.New("luno: market order type must be BUY or SELL")
+	}
+
+	f := FillEstimate{
+		Base:       decimal.Zero(),
+		Counter:    decimal.Zero(),
+		VWAP:       decimal.Zero(),
+		WorstPrice: decimal.Zero(),
+		Mid:        decimal.Zero(),
+		Slippage:   decimal.Zero(),
+	}
+	if len(bids) > 0 && len(asks) > 0 {
+		f.Mid = bids[0].Price.Add(asks[0].Price).Mul(half)
+	}
+
+	byBase := base.Sign() > 0
+	remaining := counter
+	if byBase {
+		remaining = base
+	}
+
+	var volumeScale int
+	for _, l := range levels {
+		if remaining.Sign() <= 0 {
+			break
+		}
+		if s := l.Volume.Scale(); s > volumeScale {
+			volumeScale = s
+		}
+
+		b, c := l.Volume, l.Price.Mul(l.Volume)
+		partial := false
+		if byBase && b.Cmp(remaining) > 0 {
+			b, c, partial = remaining, remaining.Mul(l.Price), true
+		} else if !byBase && c.Cmp(remaining) > 0 {
+			b = remaining.Div(l.Price, volumeScale)
+			c, partial = b.Mul(l.Price), true
+		}
+		if b.Sign() <= 0 {
+			break
+		}
+
+		f.Base = f.Base.Add(b)
+		f.Counter = f.Counter.Add(c)
+		f.WorstPrice = l.Price
+		if byBase {
+			remaining = remaining.Sub(b)
+		} else {
+			remaining = remaining.Sub(c)
+		}
+		if partial {
+			break
+		}
+	}
+	f.Unfilled = remaining
+
+	if f.Base.Sign() > 0 {
+		f.VWAP = f.Counter.Div(f.Base, f.WorstPrice.Scale()+vwapDigits)
+		if f.Mid.Sign() > 0 {
+			f.Slippage = f.VWAP.Sub(f.Mid)
+			if typ == OrderTypeSell {
+				f.Slippage = f.Slippage.Neg()
+			}
+		}
+	}
+	return f, nil
+}
+
+// EstimateMarketOrder simulates a market order against the order book. See
+// the EstimateMarketOrder function.
+func (r *GetOrderBookResponse) EstimateMarketOrder(typ OrderType,
+	base, counter decimal.Decimal) (FillEstimate, error) {
+
+	return EstimateMarketOrder(r.Bids, r.Asks, typ, base, counter)
+}
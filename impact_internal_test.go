@@ -0,0 +1,114 @@
+package luno
+
+import (
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func testDecimal(t *testing.T, s string) decimal.Decimal {
+	if s == "" {
+		return decimal.Decimal{}
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEstimateMarketOrder(t *testing.T) {
+	entry := func(price, volume string) OrderBookEntry {
+		return OrderBookEntry{
+			Price:  testDecimal(t, price),
+			Volume: testDecimal(t, volume),
+		}
+	}
+	book := GetOrderBookResponse{
+		Bids: []OrderBookEntry{entry("99", "1.0"), entry("98", "2.0")},
+		Asks: []OrderBookEntry{entry("101", "1.0"), entry("102", "0.5"), entry("104", "1.5")},
+	}
+
+	type testCase struct {
+		name          string
+		typ           OrderType
+		base, counter string
+
+		expBase, expCounter, expVWAP, expWorst, expSlippage, expUnfilled string
+	}
+
+	testCases := []testCase{
+		{
+			name: "buy within best level", typ: OrderTypeBuy, base: "0.5",
+			expBase: "0.5", expCounter: "50.5", expVWAP: "101",
+			expWorst: "101", expSlippage: "1", expUnfilled: "0",
+		},
+		{
+			name: "buy across levels", typ: OrderTypeBuy, base: "2",
+			expBase: "2", expCounter: "204", expVWAP: "102",
+			expWorst: "104", expSlippage: "2", expUnfilled: "0",
+		},
+		{
+			name: "buy more than the book", typ: OrderTypeBuy, base: "4",
+			expBase: "3.0", expCounter: "308", expVWAP: "102.666",
+			expWorst: "104", expSlippage: "2.666", expUnfilled: "1.0",
+		},
+		{
+			name: "buy by counter", typ: OrderTypeBuy, counter: "200",
+			expBase: "1.9", expCounter: "193.6", expVWAP: "101.894",
+			expWorst: "104", expSlippage: "1.894", expUnfilled: "6.4",
+		},
+		{
+			name: "sell across levels", typ: OrderTypeSell, base: "2",
+			expBase: "2", expCounter: "197", expVWAP: "98.5",
+			expWorst: "98", expSlippage: "1.5", expUnfilled: "0",
+		},
+		{
+			name: "sell by counter", typ: OrderTypeSell, counter: "99",
+			expBase: "1.0", expCounter: "99.0", expVWAP: "99.0",
+			expWorst: "99", expSlippage: "1.0", expUnfilled: "0.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		f, err := book.EstimateMarketOrder(tc.typ,
+			testDecimal(t, tc.base), testDecimal(t, tc.counter))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		check := func(field, exp string, act decimal.Decimal) {
+			if act.Cmp(testDecimal(t, exp)) != 0 {
+				t.Errorf("%s: expected %s %s, got %s", tc.name, field, exp, act)
+			}
+		}
+		check("base", tc.expBase, f.Base)
+		check("counter", tc.expCounter, f.Counter)
+		check("VWAP", tc.expVWAP, f.VWAP.ToScale(3))
+		check("worst price", tc.expWorst, f.WorstPrice)
+		check("mid", "100", f.Mid)
+		check("slippage", tc.expSlippage, f.Slippage.ToScale(3))
+		check("unfilled", tc.expUnfilled, f.Unfilled)
+	}
+}
+
+func TestEstimateMarketOrderErrors(t *testing.T) {
+	one := decimal.NewFromInt64(1)
+	if _, err := EstimateMarketOrder(nil, nil, OrderTypeBid, one, decimal.Decimal{}); err == nil {
+		t.Errorf("Expected error for BID order type")
+	}
+	if _, err := EstimateMarketOrder(nil, nil, OrderTypeBuy, one, one); err == nil {
+		t.Errorf("Expected error for both base and counter volume")
+	}
+	if _, err := EstimateMarketOrder(nil, nil, OrderTypeBuy, decimal.Decimal{}, decimal.Decimal{}); err == nil {
+		t.Errorf("Expected error for no volume")
+	}
+
+	f, err := EstimateMarketOrder(nil, nil, OrderTypeBuy, one, decimal.Decimal{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Base.Sign() != 0 || f.Unfilled.Cmp(one) != 0 {
+		t.Errorf("Expected nothing filled from an empty book, got %+v", f)
+	}
+}
@@ -0,0 +1,342 @@
+// Command apigen generates the luno.API interface, the lunomock fake and the
+// command table of the luno CLI from the Client methods in api.go. Run it with
+// go generate from the repository root.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+var (
+	apiFile  = flag.String("api", "api.go", "File containing the Client methods")
+	ifaceOut = flag.String("iface", "iface.go", "Output file for the API interface")
+	mockOut  = flag.String("mock", "lunomock/lunomock.go", "Output file for the fake")
+	cmdOut   = flag.String("cmd", "cmd/luno/commands.go", "Output file for the CLI commands")
+)
+
+type method struct {
+	Name string
+	Doc  string
+	Req  string
+	Res  string
+
+	// Command is the name of the CLI command, e.g. get-ticker.
+	Command string
+	// Summary is the first sentence of the method's description.
+	Summary string
+	// Fields are the request fields which can be set with URL parameters.
+	Fields []field
+}
+
+type field struct {
+	Name     string
+	Usage    string
+	Required bool
+}
+
+func main() {
+	flag.Parse()
+
+	methods, err := parseMethods(*apiFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := render(*ifaceOut, ifaceTmpl, methods); err != nil {
+		log.Fatal(err)
+	}
+	if err := render(*mockOut, mockTmpl, methods); err != nil {
+		log.Fatal(err)
+	}
+	if err := render(*cmdOut, cmdTmpl, methods); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseMethods returns the exported methods on *Client in the given file which
+// have the signature func(context.Context, *XRequest) (*XResponse, error).
+func parseMethods(path string) ([]method, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := parseRequestFields(f)
+
+	var methods []method
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || !fn.Name.IsExported() {
+			continue
+		}
+		if typeName(fn.Recv.List[0].Type) != "*Client" {
+			continue
+		}
+		params, results := fn.Type.Params.List, fn.Type.Results.List
+		if len(params) != 2 || len(results) != 2 {
+			continue
+		}
+
+		var doc, summary string
+		if fn.Doc != nil {
+			text := fn.Doc.Text()
+			doc = strings.SplitN(text, "\n", 2)[0]
+			if paras := strings.SplitN(text, "\n\n", 3); len(paras) > 1 {
+				summary = firstSentence(paras[1])
+			}
+		}
+
+		req := strings.TrimPrefix(typeName(params[1].Type), "*")
+		methods = append(methods, method{
+			Name:    fn.Name.Name,
+			Doc:     doc,
+			Req:     req,
+			Res:     strings.TrimPrefix(typeName(results[0].Type), "*"),
+			Command: kebab(fn.Name.Name),
+			Summary: summary,
+			Fields:  fields[req],
+		})
+	}
+	return methods, nil
+}
+
+// parseRequestFields returns the fields with url tags of the request structs
+// in f, by struct name.
+func parseRequestFields(f *ast.File) map[string][]field {
+	structs := make(map[string][]field)
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || !strings.HasSuffix(ts.Name.Name, "Request") {
+				continue
+			}
+			var fields []field
+			for _, fd := range st.Fields.List {
+				if fd.Tag == nil {
+					continue
+				}
+				tag := reflect.StructTag(strings.Trim(fd.Tag.Value, "`"))
+				name := tag.Get("url")
+				if name == "" || name == "-" {
+					continue
+				}
+				var doc string
+				if fd.Doc != nil {
+					doc = fd.Doc.Text()
+				}
+				fields = append(fields, field{
+					Name:     name,
+					Usage:    firstSentence(strings.SplitN(doc, "\n\n", 2)[0]),
+					Required: strings.Contains(doc, "required: true"),
+				})
+			}
+			structs[ts.Name.Name] = fields
+		}
+	}
+	return structs
+}
+
+var (
+	lineBreaks = regexp.MustCompile(`<br ?/?>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// firstSentence returns the first sentence of a doc comment paragraph as a
+// single line of plain text.
+func firstSentence(para string) string {
+	s := strings.Join(strings.Fields(para), " ")
+	s = lineBreaks.ReplaceAllString(s, " ")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = strings.Join(strings.Fields(s), " ")
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '.' || s[i+1] != ' ' {
+			continue
+		}
+		if strings.HasSuffix(s[:i], "e.g") || strings.HasSuffix(s[:i], "i.e") {
+			continue
+		}
+		return s[:i+1]
+	}
+	return s
+}
+
+// kebab converts a method name like GetOrderBook to get-order-book.
+func kebab(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func typeName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + typeName(t.X)
+	case *ast.SelectorExpr:
+		return typeName(t.X) + "." + t.Sel.Name
+	}
+	return ""
+}
+
+func render(path string, tmpl *template.Template, methods []method) error {
+	src, err := generate(tmpl, methods)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, src, 0644)
+}
+
+func generate(tmpl *template.Template, methods []method) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, methods); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var ifaceTmpl = template.Must(template.New("iface").Parse(`// Code generated by internal/apigen. DO NOT EDIT.
+
+package luno
+
+import "context"
+
+// API is the interface implemented by Client. Depend on it instead of *Client
+// to substitute a fake, e.g. lunomock.Client, in tests.
+type API interface {
+{{- range .}}
+	// {{.Doc}}
+	{{.Name}}(ctx context.Context, req *{{.Req}}) (*{{.Res}}, error)
+{{- end}}
+}
+
+var _ API = (*Client)(nil)
+`))
+
+var mockTmpl = template.Must(template.New("mock").Parse(`// Code generated by internal/apigen. DO NOT EDIT.
+
+// Package lunomock provides a fake implementation of luno.API for tests.
+//
+// Each method records its requests and returns the result of the matching
+// function field, e.g. GetTickerFunc. Calling a method whose function is not
+// set returns ErrNotScripted.
+package lunomock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	luno "github.com/luno/luno-go"
+)
+
+// ErrNotScripted is returned by methods which have no function set.
+var ErrNotScripted = errors.New("lunomock: call not scripted")
+
+// Client is a fake luno.API. The zero value is ready to use. Function fields
+// must be set before the Client is used concurrently.
+type Client struct {
+{{- range .}}
+	{{.Name}}Func func(ctx context.Context, req *luno.{{.Req}}) (*luno.{{.Res}}, error)
+{{- end}}
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Call is a recorded call to the fake.
+type Call struct {
+	Operation string
+	Request   interface{}
+}
+
+var _ luno.API = (*Client)(nil)
+
+// Calls returns all calls made so far, in order.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+{{range .}}
+// {{.Name}} records req and calls {{.Name}}Func.
+func (c *Client) {{.Name}}(ctx context.Context, req *luno.{{.Req}}) (*luno.{{.Res}}, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "{{.Name}}", Request: req})
+	c.mu.Unlock()
+
+	if c.{{.Name}}Func == nil {
+		return nil, ErrNotScripted
+	}
+	return c.{{.Name}}Func(ctx, req)
+}
+
+// {{.Name}}Calls returns the requests passed to {{.Name}} so far, in order.
+func (c *Client) {{.Name}}Calls() []*luno.{{.Req}} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.{{.Req}}
+	for _, call := range c.calls {
+		if call.Operation == "{{.Name}}" {
+			reqs = append(reqs, call.Request.(*luno.{{.Req}}))
+		}
+	}
+	return reqs
+}
+{{end}}`))
+
+var cmdTmpl = template.Must(template.New("cmd").Parse(`// Code generated by internal/apigen. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+
+	luno "github.com/luno/luno-go"
+)
+
+var commands = []*command{
+{{- range .}}
+	{
+		name:    "{{.Command}}",
+		summary: {{printf "%q" .Summary}},
+		flags: []flagDoc{
+		{{- range .Fields}}
+			{name: "{{.Name}}", usage: {{printf "%q" .Usage}}, required: {{.Required}}},
+		{{- end}}
+		},
+		newRequest: func() interface{} { return new(luno.{{.Req}}) },
+		call: func(ctx context.Context, cl luno.API, req interface{}) (interface{}, error) {
+			return cl.{{.Name}}(ctx, req.(*luno.{{.Req}}))
+		},
+	},
+{{- end}}
+}
+`))
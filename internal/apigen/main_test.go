@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+// TestGeneratedFilesUpToDate fails if api.go has changed without running
+// go generate.
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+
+	methods, err := parseMethods(filepath.Join(root, "api.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) == 0 {
+		t.Fatal("Expected methods to be found in api.go")
+	}
+
+	for path, tmpl := range map[string]*template.Template{
+		"iface.go":             ifaceTmpl,
+		"lunomock/lunomock.go": mockTmpl,
+		"cmd/luno/commands.go": cmdTmpl,
+	} {
+		exp, err := generate(tmpl, methods)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, err := ioutil.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp, act) {
+			t.Errorf("%s is out of date, run go generate", path)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package luno
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Field is a key-value pair attached to a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is a leveled, structured logger. Implementations must be safe for
+// concurrent use. Sensitive values are redacted before they are passed to the
+// logger.
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// NewStdLogger returns a Logger which writes to l, or to the standard logger
+// if l is nil. Messages below minLevel are discarded.
+func NewStdLogger(l *log.Logger, minLevel Level) Logger {
+	return &stdLogger{l: l, minLevel: minLevel}
+}
+
+type stdLogger struct {
+	l        *log.Logger
+	minLevel Level
+}
+
+func (s *stdLogger) Log(level Level, msg string, fields ...Field) {
+	if level < s.minLevel {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "luno: [%s] %s", level, msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+
+	if s.l == nil {
+		log.Print(b.String())
+	} else {
+		s.l.Print(b.String())
+	}
+}
+
+// SetLogger sets the logger used by the client. By default, messages are
+// written to the standard logger. Debug messages, which include redacted
+// requests and responses, are only logged in debug mode.
+func (cl *Client) SetLogger(logger Logger) {
+	cl.logger = logger
+}
+
+func (cl *Client) log(level Level, msg string, fields ...Field) {
+	if level == LevelDebug && !cl.debug {
+		return
+	}
+	logger := cl.logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+	logger.Log(level, msg, fields...)
+}
+
+var defaultLogger = NewStdLogger(nil, LevelDebug)
@@ -0,0 +1,117 @@
+package luno
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *recordingLogger) Log(level Level, msg string, fields ...Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf("%s %s %v", level, msg, fields))
+}
+
+func (r *recordingLogger) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.lines, "\n")
+}
+
+func TestDebugLoggingRedacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"withdrawal_id":"123","nested":[{"address":"respaddr"}]}`))
+	}))
+	defer srv.Close()
+
+	logger := new(recordingLogger)
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetLogger(logger)
+
+	req := SendRequest{
+		Address:  "reqaddr",
+		Amount:   decimal.NewFromInt64(42),
+		Currency: "XBT",
+	}
+
+	// Nothing is logged outside of debug mode.
+	if _, err := cl.Send(context.Background(), &req); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if s := logger.String(); s != "" {
+		t.Errorf("Expected no log output, got %q", s)
+	}
+
+	cl.SetDebug(true)
+	if _, err := cl.Send(context.Background(), &req); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	s := logger.String()
+	for _, secret := range []string{"reqaddr", "respaddr", "42"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("Expected %q to be redacted, got %q", secret, s)
+		}
+	}
+	for _, public := range []string{"XBT", "withdrawal_id", "/api/1/send"} {
+		if !strings.Contains(s, public) {
+			t.Errorf("Expected %q to be logged, got %q", public, s)
+		}
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+
+	l.Log(LevelDebug, "hidden")
+	l.Log(LevelWarn, "shown", Field{"a", 1}, Field{"b", "two"})
+
+	exp := "luno: [warn] shown a=1 b=two\n"
+	if buf.String() != exp {
+		t.Errorf("Expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	testCases := []struct {
+		in  string
+		exp string
+	}{
+		{in: `not json`, exp: `<8 bytes>`},
+		{in: `{"pair":"XBTZAR"}`, exp: `{"pair":"XBTZAR"}`},
+		{in: `{"balance":[{"balance":"1.0","asset":"XBT"}]}`, exp: `{"balance":"[REDACTED]"}`},
+		{in: `[{"address":"abc","asset":"XBT"}]`, exp: `[{"address":"[REDACTED]","asset":"XBT"}]`},
+	}
+
+	for _, test := range testCases {
+		act := redactJSON([]byte(test.in))
+		if act != test.exp {
+			t.Errorf("Expected %q to redact to %q, got %q", test.in, test.exp, act)
+		}
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	if s := RedactString("short"); s != Redacted {
+		t.Errorf("Expected %q, got %q", Redacted, s)
+	}
+	if s := RedactString("abcdefghijkl"); s != "abcd..."+Redacted {
+		t.Errorf("Expected partial redaction, got %q", s)
+	}
+}
@@ -1,6 +1,8 @@
 // Package luno is a wrapper for the Luno API.
 package luno
 
+//go:generate go run ./internal/apigen
+
 import (
 	"bytes"
 	"context"
@@ -9,27 +11,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"runtime"
 	"strings"
 	"time"
 )
 
-// Error is a Luno API error.
-type Error struct {
-	// Code can be used to identify errors even if the error message is
-	// localised.
-	Code string `json:"error_code"`
-
-	// Message may be localised for authenticated API calls.
-	Message string `json:"error"`
-}
-
-func (e *Error) Error() string {
-	return e.Message
-}
-
 // Client is a Luno API client.
 type Client struct {
 	httpClient   *http.Client
@@ -37,6 +24,14 @@ type Client struct {
 	apiKeyID     string
 	apiKeySecret string
 	debug        bool
+	logger       Logger
+
+	globalLimiter  *rateLimiter
+	publicLimiter  *rateLimiter
+	privateLimiter *rateLimiter
+
+	retryPolicy RetryPolicy
+	middleware  []Middleware
 }
 
 const defaultBaseURL = "https://api.mybitx.com"
@@ -79,19 +74,58 @@ func (cl *Client) SetBaseURL(baseURL string) {
 }
 
 // SetDebug enables or disables debug mode. In debug mode, HTTP requests and
-// responses will be logged.
+// responses will be logged at debug level, with sensitive values redacted.
 func (cl *Client) SetDebug(debug bool) {
 	cl.debug = debug
 }
 
-func (cl *Client) do(ctx context.Context, method, path string,
+func (cl *Client) do(ctx context.Context, op, method, path string,
 	req, res interface{}, auth bool) error {
 
+	call := &Call{
+		Operation: op,
+		Method:    method,
+		Path:      path,
+		Auth:      auth,
+		Request:   req,
+	}
+	return cl.handler(cl.doCall)(ctx, call, res)
+}
+
+// doCall is the innermost Handler. It makes the call, retrying if allowed by
+// the client's retry policy.
+func (cl *Client) doCall(ctx context.Context, call *Call, res interface{}) error {
+	method, path, req, auth := call.Method, call.Path, call.Request, call.Auth
+
+	for attempt := 1; ; attempt++ {
+		err := cl.doOnce(ctx, method, path, req, res, auth)
+		if err == nil || attempt >= cl.retryPolicy.MaxAttempts ||
+			!canRetry(ctx, method) || !IsRetryable(err) {
+			return err
+		}
+
+		wait := cl.retryPolicy.backoff(attempt, err)
+		cl.log(LevelWarn, "Retrying call",
+			Field{"method", method}, Field{"path", path},
+			Field{"wait", wait}, Field{"error", err})
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (cl *Client) doOnce(ctx context.Context, method, path string,
+	req, res interface{}, auth bool) error {
+
+	if err := cl.waitRateLimit(ctx, auth); err != nil {
+		return err
+	}
+
 	url := cl.baseURL + "/" + strings.TrimLeft(path, "/")
 
 	if cl.debug {
-		log.Printf("luno: Call: %s %s", method, path)
-		log.Printf("luno: Request: %#v", req)
+		cl.log(LevelDebug, "Call", Field{"method", method},
+			Field{"path", path}, Field{"request", redactRequest(req)})
 	}
 
 	var contentType string
@@ -127,6 +161,10 @@ func (cl *Client) do(ctx context.Context, method, path string,
 		httpReq.SetBasicAuth(cl.apiKeyID, cl.apiKeySecret)
 	}
 
+	if key := getIdempotencyKey(ctx); key != "" {
+		httpReq.Header.Set("Idempotency-Key", key)
+	}
+
 	if method != http.MethodGet {
 		httpReq.Header.Set("content-type", "application/x-www-form-urlencoded")
 	}
@@ -141,24 +179,30 @@ func (cl *Client) do(ctx context.Context, method, path string,
 	if cl.debug {
 		b, err := ioutil.ReadAll(body)
 		if err != nil {
-			log.Printf("luno: Error reading response body: %v", err)
+			cl.log(LevelError, "Error reading response body",
+				Field{"error", err})
 		} else {
-			log.Printf("Response: %s", string(b))
+			cl.log(LevelDebug, "Response", Field{"status", httpRes.StatusCode},
+				Field{"body", redactJSON(b)})
 		}
 		body = bytes.NewReader(b)
 	}
 
 	if httpRes.StatusCode == http.StatusTooManyRequests {
-		return errors.New("luno: too many requests")
+		return newHTTPError(method, path, httpRes, Error{
+			Message: "too many requests",
+		})
 	}
 
 	if httpRes.StatusCode != http.StatusOK {
 		var e Error
 		if err := json.NewDecoder(body).Decode(&e); err != nil {
-			return fmt.Errorf("luno: error decoding response (%d %s)",
-				httpRes.StatusCode, http.StatusText(httpRes.StatusCode))
+			return newHTTPError(method, path, httpRes, Error{
+				Message: fmt.Sprintf("error decoding response (%d %s)",
+					httpRes.StatusCode, http.StatusText(httpRes.StatusCode)),
+			})
 		}
-		return fmt.Errorf("luno: %s (%s)", e.Message, e.Code)
+		return newHTTPError(method, path, httpRes, e)
 	}
 
 	// The API returns errors as 200s, even if we get a 200 we still have to
@@ -168,7 +212,7 @@ func (cl *Client) do(ctx context.Context, method, path string,
 	tee := io.TeeReader(body, teeBuf)
 	var e Error
 	if err := json.NewDecoder(tee).Decode(&e); err == nil && e.Code != "" {
-		return fmt.Errorf("luno: %s (%s)", e.Message, e.Code)
+		return newHTTPError(method, path, httpRes, e)
 	}
 	return json.NewDecoder(teeBuf).Decode(res)
 }
@@ -3,6 +3,7 @@ package luno
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -25,7 +26,7 @@ func TestDoGet(t *testing.T) {
 	cl.SetBaseURL(srv.URL)
 
 	var res testRes
-	err := cl.do(context.Background(), "GET", "/test", nil, &res, false)
+	err := cl.do(context.Background(), "", "GET", "/test", nil, &res, false)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -51,7 +52,7 @@ func TestDoGetWithParameters(t *testing.T) {
 	req := testReq{Value: now}
 
 	var res testRes
-	err := cl.do(context.Background(), "GET", "/test", &req, &res, false)
+	err := cl.do(context.Background(), "", "GET", "/test", &req, &res, false)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -84,7 +85,7 @@ func TestDoPost(t *testing.T) {
 	req := testReq{Value: now}
 
 	var res testRes
-	err := cl.do(context.Background(), "POST", "/test", &req, &res, false)
+	err := cl.do(context.Background(), "", "POST", "/test", &req, &res, false)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -119,7 +120,7 @@ func TestDoAuth(t *testing.T) {
 	var res testRes
 
 	// No auth provided:
-	err := cl.do(context.Background(), "POST", "/test", nil, &res, false)
+	err := cl.do(context.Background(), "", "POST", "/test", nil, &res, false)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -128,7 +129,7 @@ func TestDoAuth(t *testing.T) {
 	}
 
 	// Auth provided:
-	err = cl.do(context.Background(), "POST", "/test", nil, &res, true)
+	err = cl.do(context.Background(), "", "POST", "/test", nil, &res, true)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -166,7 +167,7 @@ func TestDoURLReplacement(t *testing.T) {
 	req := testReq{ID: r, Value: value}
 
 	var res testRes
-	err := cl.do(context.Background(), "GET", "/test/{id}", &req, &res, false)
+	err := cl.do(context.Background(), "", "GET", "/test/{id}", &req, &res, false)
 	if err != nil {
 		t.Errorf("Expected success, got %v", err)
 	}
@@ -190,7 +191,7 @@ func TestDoJSONError(t *testing.T) {
 	cl.SetBaseURL(srv.URL)
 
 	var res interface{}
-	err := cl.do(context.Background(), "GET", "/", nil, &res, false)
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
 	if err == nil {
 		t.Errorf("Expected error, got nil")
 	}
@@ -200,3 +201,122 @@ func TestDoJSONError(t *testing.T) {
 			str400, err.Error())
 	}
 }
+
+func TestDoAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Error{
+			Code:    "ErrInsufficientBalance",
+			Message: "Insufficient balance",
+		})
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "POST", "/api/1/postorder", nil, &res, false)
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Expected *Error, got %T", err)
+	}
+	if e.HTTPStatus != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, e.HTTPStatus)
+	}
+	if e.Method != "POST" || e.Path != "/api/1/postorder" {
+		t.Errorf("Expected POST /api/1/postorder, got %s %s", e.Method, e.Path)
+	}
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected error to match ErrInsufficientBalance")
+	}
+	if errors.Is(err, ErrInvalidPair) {
+		t.Errorf("Expected error not to match ErrInvalidPair")
+	}
+	exp := "luno: Insufficient balance (ErrInsufficientBalance)"
+	if err.Error() != exp {
+		t.Errorf("Expected %q, got %q", exp, err.Error())
+	}
+}
+
+func TestDoAPIErrorWithOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Error{
+			Code:    "ErrInvalidPair",
+			Message: "Invalid pair",
+		})
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/api/1/ticker", nil, &res, false)
+	if !errors.Is(err, ErrInvalidPair) {
+		t.Errorf("Expected ErrInvalidPair, got %v", err)
+	}
+}
+
+func TestDoTooManyRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("Expected ErrTooManyRequests, got %v", err)
+	}
+	var e *Error
+	errors.As(err, &e)
+	if e.RetryAfter != 3*time.Second {
+		t.Errorf("Expected retry after 3s, got %s", e.RetryAfter)
+	}
+}
+
+func TestDoUnauthorised(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, true)
+	if !errors.Is(err, ErrUnauthorised) {
+		t.Errorf("Expected ErrUnauthorised, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		in  string
+		exp time.Duration
+	}{
+		{in: "", exp: 0},
+		{in: "abc", exp: 0},
+		{in: "-1", exp: 0},
+		{in: "120", exp: 2 * time.Minute},
+		{in: "Mon, 01 Jan 2018 00:00:30 GMT", exp: 30 * time.Second},
+		{in: "Sun, 31 Dec 2017 23:59:00 GMT", exp: 0},
+	}
+
+	for _, test := range testCases {
+		act := parseRetryAfter(test.in, now)
+		if act != test.exp {
+			t.Errorf("Expected %q to parse as %s, got %s", test.in, test.exp, act)
+		}
+	}
+}
@@ -0,0 +1,744 @@
+// Code generated by internal/apigen. DO NOT EDIT.
+
+// Package lunomock provides a fake implementation of luno.API for tests.
+//
+// Each method records its requests and returns the result of the matching
+// function field, e.g. GetTickerFunc. Calling a method whose function is not
+// set returns ErrNotScripted.
+package lunomock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	luno "github.com/luno/luno-go"
+)
+
+// ErrNotScripted is returned by methods which have no function set.
+var ErrNotScripted = errors.New("lunomock: call not scripted")
+
+// Client is a fake luno.API. The zero value is ready to use. Function fields
+// must be set before the Client is used concurrently.
+type Client struct {
+	CancelWithdrawalFunc        func(ctx context.Context, req *luno.CancelWithdrawalRequest) (*luno.CancelWithdrawalResponse, error)
+	CreateAccountFunc           func(ctx context.Context, req *luno.CreateAccountRequest) (*luno.CreateAccountResponse, error)
+	CreateFundingAddressFunc    func(ctx context.Context, req *luno.CreateFundingAddressRequest) (*luno.CreateFundingAddressResponse, error)
+	CreateQuoteFunc             func(ctx context.Context, req *luno.CreateQuoteRequest) (*luno.CreateQuoteResponse, error)
+	CreateWithdrawalFunc        func(ctx context.Context, req *luno.CreateWithdrawalRequest) (*luno.CreateWithdrawalResponse, error)
+	DiscardQuoteFunc            func(ctx context.Context, req *luno.DiscardQuoteRequest) (*luno.DiscardQuoteResponse, error)
+	ExerciseQuoteFunc           func(ctx context.Context, req *luno.ExerciseQuoteRequest) (*luno.ExerciseQuoteResponse, error)
+	GetBalancesFunc             func(ctx context.Context, req *luno.GetBalancesRequest) (*luno.GetBalancesResponse, error)
+	GetFeeInfoFunc              func(ctx context.Context, req *luno.GetFeeInfoRequest) (*luno.GetFeeInfoResponse, error)
+	GetFundingAddressFunc       func(ctx context.Context, req *luno.GetFundingAddressRequest) (*luno.GetFundingAddressResponse, error)
+	GetOrderFunc                func(ctx context.Context, req *luno.GetOrderRequest) (*luno.GetOrderResponse, error)
+	GetOrderBookFunc            func(ctx context.Context, req *luno.GetOrderBookRequest) (*luno.GetOrderBookResponse, error)
+	GetQuoteFunc                func(ctx context.Context, req *luno.GetQuoteRequest) (*luno.GetQuoteResponse, error)
+	GetTickerFunc               func(ctx context.Context, req *luno.GetTickerRequest) (*luno.GetTickerResponse, error)
+	GetTickersFunc              func(ctx context.Context, req *luno.GetTickersRequest) (*luno.GetTickersResponse, error)
+	GetWithdrawalFunc           func(ctx context.Context, req *luno.GetWithdrawalRequest) (*luno.GetWithdrawalResponse, error)
+	ListOrdersFunc              func(ctx context.Context, req *luno.ListOrdersRequest) (*luno.ListOrdersResponse, error)
+	ListPendingTransactionsFunc func(ctx context.Context, req *luno.ListPendingTransactionsRequest) (*luno.ListPendingTransactionsResponse, error)
+	ListTradesFunc              func(ctx context.Context, req *luno.ListTradesRequest) (*luno.ListTradesResponse, error)
+	ListTransactionsFunc        func(ctx context.Context, req *luno.ListTransactionsRequest) (*luno.ListTransactionsResponse, error)
+	ListUserTradesFunc          func(ctx context.Context, req *luno.ListUserTradesRequest) (*luno.ListUserTradesResponse, error)
+	ListWithdrawalsFunc         func(ctx context.Context, req *luno.ListWithdrawalsRequest) (*luno.ListWithdrawalsResponse, error)
+	PostLimitOrderFunc          func(ctx context.Context, req *luno.PostLimitOrderRequest) (*luno.PostLimitOrderResponse, error)
+	PostMarketOrderFunc         func(ctx context.Context, req *luno.PostMarketOrderRequest) (*luno.PostMarketOrderResponse, error)
+	SendFunc                    func(ctx context.Context, req *luno.SendRequest) (*luno.SendResponse, error)
+	StopOrderFunc               func(ctx context.Context, req *luno.StopOrderRequest) (*luno.StopOrderResponse, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Call is a recorded call to the fake.
+type Call struct {
+	Operation string
+	Request   interface{}
+}
+
+var _ luno.API = (*Client)(nil)
+
+// Calls returns all calls made so far, in order.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CancelWithdrawal records req and calls CancelWithdrawalFunc.
+func (c *Client) CancelWithdrawal(ctx context.Context, req *luno.CancelWithdrawalRequest) (*luno.CancelWithdrawalResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "CancelWithdrawal", Request: req})
+	c.mu.Unlock()
+
+	if c.CancelWithdrawalFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.CancelWithdrawalFunc(ctx, req)
+}
+
+// CancelWithdrawalCalls returns the requests passed to CancelWithdrawal so far, in order.
+func (c *Client) CancelWithdrawalCalls() []*luno.CancelWithdrawalRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.CancelWithdrawalRequest
+	for _, call := range c.calls {
+		if call.Operation == "CancelWithdrawal" {
+			reqs = append(reqs, call.Request.(*luno.CancelWithdrawalRequest))
+		}
+	}
+	return reqs
+}
+
+// CreateAccount records req and calls CreateAccountFunc.
+func (c *Client) CreateAccount(ctx context.Context, req *luno.CreateAccountRequest) (*luno.CreateAccountResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "CreateAccount", Request: req})
+	c.mu.Unlock()
+
+	if c.CreateAccountFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.CreateAccountFunc(ctx, req)
+}
+
+// CreateAccountCalls returns the requests passed to CreateAccount so far, in order.
+func (c *Client) CreateAccountCalls() []*luno.CreateAccountRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.CreateAccountRequest
+	for _, call := range c.calls {
+		if call.Operation == "CreateAccount" {
+			reqs = append(reqs, call.Request.(*luno.CreateAccountRequest))
+		}
+	}
+	return reqs
+}
+
+// CreateFundingAddress records req and calls CreateFundingAddressFunc.
+func (c *Client) CreateFundingAddress(ctx context.Context, req *luno.CreateFundingAddressRequest) (*luno.CreateFundingAddressResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "CreateFundingAddress", Request: req})
+	c.mu.Unlock()
+
+	if c.CreateFundingAddressFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.CreateFundingAddressFunc(ctx, req)
+}
+
+// CreateFundingAddressCalls returns the requests passed to CreateFundingAddress so far, in order.
+func (c *Client) CreateFundingAddressCalls() []*luno.CreateFundingAddressRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.CreateFundingAddressRequest
+	for _, call := range c.calls {
+		if call.Operation == "CreateFundingAddress" {
+			reqs = append(reqs, call.Request.(*luno.CreateFundingAddressRequest))
+		}
+	}
+	return reqs
+}
+
+// CreateQuote records req and calls CreateQuoteFunc.
+func (c *Client) CreateQuote(ctx context.Context, req *luno.CreateQuoteRequest) (*luno.CreateQuoteResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "CreateQuote", Request: req})
+	c.mu.Unlock()
+
+	if c.CreateQuoteFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.CreateQuoteFunc(ctx, req)
+}
+
+// CreateQuoteCalls returns the requests passed to CreateQuote so far, in order.
+func (c *Client) CreateQuoteCalls() []*luno.CreateQuoteRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.CreateQuoteRequest
+	for _, call := range c.calls {
+		if call.Operation == "CreateQuote" {
+			reqs = append(reqs, call.Request.(*luno.CreateQuoteRequest))
+		}
+	}
+	return reqs
+}
+
+// CreateWithdrawal records req and calls CreateWithdrawalFunc.
+func (c *Client) CreateWithdrawal(ctx context.Context, req *luno.CreateWithdrawalRequest) (*luno.CreateWithdrawalResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "CreateWithdrawal", Request: req})
+	c.mu.Unlock()
+
+	if c.CreateWithdrawalFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.CreateWithdrawalFunc(ctx, req)
+}
+
+// CreateWithdrawalCalls returns the requests passed to CreateWithdrawal so far, in order.
+func (c *Client) CreateWithdrawalCalls() []*luno.CreateWithdrawalRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.CreateWithdrawalRequest
+	for _, call := range c.calls {
+		if call.Operation == "CreateWithdrawal" {
+			reqs = append(reqs, call.Request.(*luno.CreateWithdrawalRequest))
+		}
+	}
+	return reqs
+}
+
+// DiscardQuote records req and calls DiscardQuoteFunc.
+func (c *Client) DiscardQuote(ctx context.Context, req *luno.DiscardQuoteRequest) (*luno.DiscardQuoteResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "DiscardQuote", Request: req})
+	c.mu.Unlock()
+
+	if c.DiscardQuoteFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.DiscardQuoteFunc(ctx, req)
+}
+
+// DiscardQuoteCalls returns the requests passed to DiscardQuote so far, in order.
+func (c *Client) DiscardQuoteCalls() []*luno.DiscardQuoteRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.DiscardQuoteRequest
+	for _, call := range c.calls {
+		if call.Operation == "DiscardQuote" {
+			reqs = append(reqs, call.Request.(*luno.DiscardQuoteRequest))
+		}
+	}
+	return reqs
+}
+
+// ExerciseQuote records req and calls ExerciseQuoteFunc.
+func (c *Client) ExerciseQuote(ctx context.Context, req *luno.ExerciseQuoteRequest) (*luno.ExerciseQuoteResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ExerciseQuote", Request: req})
+	c.mu.Unlock()
+
+	if c.ExerciseQuoteFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ExerciseQuoteFunc(ctx, req)
+}
+
+// ExerciseQuoteCalls returns the requests passed to ExerciseQuote so far, in order.
+func (c *Client) ExerciseQuoteCalls() []*luno.ExerciseQuoteRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ExerciseQuoteRequest
+	for _, call := range c.calls {
+		if call.Operation == "ExerciseQuote" {
+			reqs = append(reqs, call.Request.(*luno.ExerciseQuoteRequest))
+		}
+	}
+	return reqs
+}
+
+// GetBalances records req and calls GetBalancesFunc.
+func (c *Client) GetBalances(ctx context.Context, req *luno.GetBalancesRequest) (*luno.GetBalancesResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetBalances", Request: req})
+	c.mu.Unlock()
+
+	if c.GetBalancesFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetBalancesFunc(ctx, req)
+}
+
+// GetBalancesCalls returns the requests passed to GetBalances so far, in order.
+func (c *Client) GetBalancesCalls() []*luno.GetBalancesRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetBalancesRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetBalances" {
+			reqs = append(reqs, call.Request.(*luno.GetBalancesRequest))
+		}
+	}
+	return reqs
+}
+
+// GetFeeInfo records req and calls GetFeeInfoFunc.
+func (c *Client) GetFeeInfo(ctx context.Context, req *luno.GetFeeInfoRequest) (*luno.GetFeeInfoResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetFeeInfo", Request: req})
+	c.mu.Unlock()
+
+	if c.GetFeeInfoFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetFeeInfoFunc(ctx, req)
+}
+
+// GetFeeInfoCalls returns the requests passed to GetFeeInfo so far, in order.
+func (c *Client) GetFeeInfoCalls() []*luno.GetFeeInfoRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetFeeInfoRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetFeeInfo" {
+			reqs = append(reqs, call.Request.(*luno.GetFeeInfoRequest))
+		}
+	}
+	return reqs
+}
+
+// GetFundingAddress records req and calls GetFundingAddressFunc.
+func (c *Client) GetFundingAddress(ctx context.Context, req *luno.GetFundingAddressRequest) (*luno.GetFundingAddressResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetFundingAddress", Request: req})
+	c.mu.Unlock()
+
+	if c.GetFundingAddressFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetFundingAddressFunc(ctx, req)
+}
+
+// GetFundingAddressCalls returns the requests passed to GetFundingAddress so far, in order.
+func (c *Client) GetFundingAddressCalls() []*luno.GetFundingAddressRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetFundingAddressRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetFundingAddress" {
+			reqs = append(reqs, call.Request.(*luno.GetFundingAddressRequest))
+		}
+	}
+	return reqs
+}
+
+// GetOrder records req and calls GetOrderFunc.
+func (c *Client) GetOrder(ctx context.Context, req *luno.GetOrderRequest) (*luno.GetOrderResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetOrder", Request: req})
+	c.mu.Unlock()
+
+	if c.GetOrderFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetOrderFunc(ctx, req)
+}
+
+// GetOrderCalls returns the requests passed to GetOrder so far, in order.
+func (c *Client) GetOrderCalls() []*luno.GetOrderRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetOrderRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetOrder" {
+			reqs = append(reqs, call.Request.(*luno.GetOrderRequest))
+		}
+	}
+	return reqs
+}
+
+// GetOrderBook records req and calls GetOrderBookFunc.
+func (c *Client) GetOrderBook(ctx context.Context, req *luno.GetOrderBookRequest) (*luno.GetOrderBookResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetOrderBook", Request: req})
+	c.mu.Unlock()
+
+	if c.GetOrderBookFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetOrderBookFunc(ctx, req)
+}
+
+// GetOrderBookCalls returns the requests passed to GetOrderBook so far, in order.
+func (c *Client) GetOrderBookCalls() []*luno.GetOrderBookRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetOrderBookRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetOrderBook" {
+			reqs = append(reqs, call.Request.(*luno.GetOrderBookRequest))
+		}
+	}
+	return reqs
+}
+
+// GetQuote records req and calls GetQuoteFunc.
+func (c *Client) GetQuote(ctx context.Context, req *luno.GetQuoteRequest) (*luno.GetQuoteResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetQuote", Request: req})
+	c.mu.Unlock()
+
+	if c.GetQuoteFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetQuoteFunc(ctx, req)
+}
+
+// GetQuoteCalls returns the requests passed to GetQuote so far, in order.
+func (c *Client) GetQuoteCalls() []*luno.GetQuoteRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetQuoteRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetQuote" {
+			reqs = append(reqs, call.Request.(*luno.GetQuoteRequest))
+		}
+	}
+	return reqs
+}
+
+// GetTicker records req and calls GetTickerFunc.
+func (c *Client) GetTicker(ctx context.Context, req *luno.GetTickerRequest) (*luno.GetTickerResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetTicker", Request: req})
+	c.mu.Unlock()
+
+	if c.GetTickerFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetTickerFunc(ctx, req)
+}
+
+// GetTickerCalls returns the requests passed to GetTicker so far, in order.
+func (c *Client) GetTickerCalls() []*luno.GetTickerRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetTickerRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetTicker" {
+			reqs = append(reqs, call.Request.(*luno.GetTickerRequest))
+		}
+	}
+	return reqs
+}
+
+// GetTickers records req and calls GetTickersFunc.
+func (c *Client) GetTickers(ctx context.Context, req *luno.GetTickersRequest) (*luno.GetTickersResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetTickers", Request: req})
+	c.mu.Unlock()
+
+	if c.GetTickersFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetTickersFunc(ctx, req)
+}
+
+// GetTickersCalls returns the requests passed to GetTickers so far, in order.
+func (c *Client) GetTickersCalls() []*luno.GetTickersRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetTickersRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetTickers" {
+			reqs = append(reqs, call.Request.(*luno.GetTickersRequest))
+		}
+	}
+	return reqs
+}
+
+// GetWithdrawal records req and calls GetWithdrawalFunc.
+func (c *Client) GetWithdrawal(ctx context.Context, req *luno.GetWithdrawalRequest) (*luno.GetWithdrawalResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "GetWithdrawal", Request: req})
+	c.mu.Unlock()
+
+	if c.GetWithdrawalFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.GetWithdrawalFunc(ctx, req)
+}
+
+// GetWithdrawalCalls returns the requests passed to GetWithdrawal so far, in order.
+func (c *Client) GetWithdrawalCalls() []*luno.GetWithdrawalRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.GetWithdrawalRequest
+	for _, call := range c.calls {
+		if call.Operation == "GetWithdrawal" {
+			reqs = append(reqs, call.Request.(*luno.GetWithdrawalRequest))
+		}
+	}
+	return reqs
+}
+
+// ListOrders records req and calls ListOrdersFunc.
+func (c *Client) ListOrders(ctx context.Context, req *luno.ListOrdersRequest) (*luno.ListOrdersResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListOrders", Request: req})
+	c.mu.Unlock()
+
+	if c.ListOrdersFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListOrdersFunc(ctx, req)
+}
+
+// ListOrdersCalls returns the requests passed to ListOrders so far, in order.
+func (c *Client) ListOrdersCalls() []*luno.ListOrdersRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListOrdersRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListOrders" {
+			reqs = append(reqs, call.Request.(*luno.ListOrdersRequest))
+		}
+	}
+	return reqs
+}
+
+// ListPendingTransactions records req and calls ListPendingTransactionsFunc.
+func (c *Client) ListPendingTransactions(ctx context.Context, req *luno.ListPendingTransactionsRequest) (*luno.ListPendingTransactionsResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListPendingTransactions", Request: req})
+	c.mu.Unlock()
+
+	if c.ListPendingTransactionsFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListPendingTransactionsFunc(ctx, req)
+}
+
+// ListPendingTransactionsCalls returns the requests passed to ListPendingTransactions so far, in order.
+func (c *Client) ListPendingTransactionsCalls() []*luno.ListPendingTransactionsRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListPendingTransactionsRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListPendingTransactions" {
+			reqs = append(reqs, call.Request.(*luno.ListPendingTransactionsRequest))
+		}
+	}
+	return reqs
+}
+
+// ListTrades records req and calls ListTradesFunc.
+func (c *Client) ListTrades(ctx context.Context, req *luno.ListTradesRequest) (*luno.ListTradesResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListTrades", Request: req})
+	c.mu.Unlock()
+
+	if c.ListTradesFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListTradesFunc(ctx, req)
+}
+
+// ListTradesCalls returns the requests passed to ListTrades so far, in order.
+func (c *Client) ListTradesCalls() []*luno.ListTradesRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListTradesRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListTrades" {
+			reqs = append(reqs, call.Request.(*luno.ListTradesRequest))
+		}
+	}
+	return reqs
+}
+
+// ListTransactions records req and calls ListTransactionsFunc.
+func (c *Client) ListTransactions(ctx context.Context, req *luno.ListTransactionsRequest) (*luno.ListTransactionsResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListTransactions", Request: req})
+	c.mu.Unlock()
+
+	if c.ListTransactionsFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListTransactionsFunc(ctx, req)
+}
+
+// ListTransactionsCalls returns the requests passed to ListTransactions so far, in order.
+func (c *Client) ListTransactionsCalls() []*luno.ListTransactionsRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListTransactionsRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListTransactions" {
+			reqs = append(reqs, call.Request.(*luno.ListTransactionsRequest))
+		}
+	}
+	return reqs
+}
+
+// ListUserTrades records req and calls ListUserTradesFunc.
+func (c *Client) ListUserTrades(ctx context.Context, req *luno.ListUserTradesRequest) (*luno.ListUserTradesResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListUserTrades", Request: req})
+	c.mu.Unlock()
+
+	if c.ListUserTradesFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListUserTradesFunc(ctx, req)
+}
+
+// ListUserTradesCalls returns the requests passed to ListUserTrades so far, in order.
+func (c *Client) ListUserTradesCalls() []*luno.ListUserTradesRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListUserTradesRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListUserTrades" {
+			reqs = append(reqs, call.Request.(*luno.ListUserTradesRequest))
+		}
+	}
+	return reqs
+}
+
+// ListWithdrawals records req and calls ListWithdrawalsFunc.
+func (c *Client) ListWithdrawals(ctx context.Context, req *luno.ListWithdrawalsRequest) (*luno.ListWithdrawalsResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "ListWithdrawals", Request: req})
+	c.mu.Unlock()
+
+	if c.ListWithdrawalsFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.ListWithdrawalsFunc(ctx, req)
+}
+
+// ListWithdrawalsCalls returns the requests passed to ListWithdrawals so far, in order.
+func (c *Client) ListWithdrawalsCalls() []*luno.ListWithdrawalsRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.ListWithdrawalsRequest
+	for _, call := range c.calls {
+		if call.Operation == "ListWithdrawals" {
+			reqs = append(reqs, call.Request.(*luno.ListWithdrawalsRequest))
+		}
+	}
+	return reqs
+}
+
+// PostLimitOrder records req and calls PostLimitOrderFunc.
+func (c *Client) PostLimitOrder(ctx context.Context, req *luno.PostLimitOrderRequest) (*luno.PostLimitOrderResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "PostLimitOrder", Request: req})
+	c.mu.Unlock()
+
+	if c.PostLimitOrderFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.PostLimitOrderFunc(ctx, req)
+}
+
+// PostLimitOrderCalls returns the requests passed to PostLimitOrder so far, in order.
+func (c *Client) PostLimitOrderCalls() []*luno.PostLimitOrderRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.PostLimitOrderRequest
+	for _, call := range c.calls {
+		if call.Operation == "PostLimitOrder" {
+			reqs = append(reqs, call.Request.(*luno.PostLimitOrderRequest))
+		}
+	}
+	return reqs
+}
+
+// PostMarketOrder records req and calls PostMarketOrderFunc.
+func (c *Client) PostMarketOrder(ctx context.Context, req *luno.PostMarketOrderRequest) (*luno.PostMarketOrderResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "PostMarketOrder", Request: req})
+	c.mu.Unlock()
+
+	if c.PostMarketOrderFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.PostMarketOrderFunc(ctx, req)
+}
+
+// PostMarketOrderCalls returns the requests passed to PostMarketOrder so far, in order.
+func (c *Client) PostMarketOrderCalls() []*luno.PostMarketOrderRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.PostMarketOrderRequest
+	for _, call := range c.calls {
+		if call.Operation == "PostMarketOrder" {
+			reqs = append(reqs, call.Request.(*luno.PostMarketOrderRequest))
+		}
+	}
+	return reqs
+}
+
+// Send records req and calls SendFunc.
+func (c *Client) Send(ctx context.Context, req *luno.SendRequest) (*luno.SendResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "Send", Request: req})
+	c.mu.Unlock()
+
+	if c.SendFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.SendFunc(ctx, req)
+}
+
+// SendCalls returns the requests passed to Send so far, in order.
+func (c *Client) SendCalls() []*luno.SendRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.SendRequest
+	for _, call := range c.calls {
+		if call.Operation == "Send" {
+			reqs = append(reqs, call.Request.(*luno.SendRequest))
+		}
+	}
+	return reqs
+}
+
+// StopOrder records req and calls StopOrderFunc.
+func (c *Client) StopOrder(ctx context.Context, req *luno.StopOrderRequest) (*luno.StopOrderResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Operation: "StopOrder", Request: req})
+	c.mu.Unlock()
+
+	if c.StopOrderFunc == nil {
+		return nil, ErrNotScripted
+	}
+	return c.StopOrderFunc(ctx, req)
+}
+
+// StopOrderCalls returns the requests passed to StopOrder so far, in order.
+func (c *Client) StopOrderCalls() []*luno.StopOrderRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reqs []*luno.StopOrderRequest
+	for _, call := range c.calls {
+		if call.Operation == "StopOrder" {
+			reqs = append(reqs, call.Request.(*luno.StopOrderRequest))
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,45 @@
+package lunomock_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/lunomock"
+)
+
+func TestClient(t *testing.T) {
+	var c lunomock.Client
+	c.GetTickerFunc = func(ctx context.Context, req *luno.GetTickerRequest) (*luno.GetTickerResponse, error) {
+		return &luno.GetTickerResponse{Pair: req.Pair}, nil
+	}
+
+	var api luno.API = &c
+
+	res, err := api.GetTicker(context.Background(), &luno.GetTickerRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if res.Pair != "XBTZAR" {
+		t.Errorf("Expected pair %q, got %q", "XBTZAR", res.Pair)
+	}
+
+	_, err = api.StopOrder(context.Background(), &luno.StopOrderRequest{OrderId: "BX1"})
+	if err != lunomock.ErrNotScripted {
+		t.Errorf("Expected ErrNotScripted, got %v", err)
+	}
+
+	exp := []*luno.GetTickerRequest{{Pair: "XBTZAR"}}
+	if act := c.GetTickerCalls(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected %v, got %v", exp, act)
+	}
+
+	var ops []string
+	for _, call := range c.Calls() {
+		ops = append(ops, call.Operation)
+	}
+	if !reflect.DeepEqual([]string{"GetTicker", "StopOrder"}, ops) {
+		t.Errorf("Unexpected calls %v", ops)
+	}
+}
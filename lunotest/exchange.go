@@ -0,0 +1,513 @@
+package lunotest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"github.com/luno/luno-go/streaming"
+)
+
+// volumeScale is the scale used for base volumes computed by dividing a
+// counter amount by a price, e.g. for market buys.
+const volumeScale = 8
+
+type account struct {
+	id       string
+	currency string
+	name     string
+	balance  decimal.Decimal
+	reserved decimal.Decimal
+	txns     []luno.Transaction
+}
+
+func (a *account) available() decimal.Decimal {
+	return a.balance.Sub(a.reserved)
+}
+
+func (a *account) toBalance() luno.AccountBalance {
+	return luno.AccountBalance{
+		AccountId: a.id,
+		Asset:     a.currency,
+		Balance:   a.balance,
+		Name:      a.name,
+		Reserved:  a.reserved,
+	}
+}
+
+type order struct {
+	id      string
+	pair    string
+	typ     luno.OrderType
+	price   decimal.Decimal
+	volume  decimal.Decimal
+	remain  decimal.Decimal
+	base    decimal.Decimal
+	counter decimal.Decimal
+	created time.Time
+	done    time.Time
+	state   luno.OrderState
+
+	// user is true for orders placed through the API. Other orders are
+	// liquidity added by the test and don't affect any balances.
+	user       bool
+	baseAcc    *account
+	counterAcc *account
+}
+
+func (o *order) toOrder() luno.Order {
+	var completed luno.Time
+	if !o.done.IsZero() {
+		completed = luno.Time(o.done)
+	}
+	return luno.Order{
+		Base:               o.base,
+		Counter:            o.counter,
+		CompletedTimestamp: completed,
+		CreationTimestamp:  luno.Time(o.created),
+		LimitPrice:         o.price,
+		LimitVolume:        o.volume,
+		OrderId:            o.id,
+		Pair:               o.pair,
+		State:              string(o.state),
+		Type:               string(o.typ),
+	}
+}
+
+type book struct {
+	pair          string
+	base, counter string
+
+	bids []*order // best (highest) price first, then oldest first
+	asks []*order // best (lowest) price first, then oldest first
+
+	trades    []luno.Trade
+	lastTrade decimal.Decimal
+}
+
+// insert adds o to the book behind all orders at the same or a better price.
+func (b *book) insert(o *order) {
+	if o.typ == luno.OrderTypeBid {
+		i := sort.Search(len(b.bids), func(i int) bool {
+			return b.bids[i].price.Cmp(o.price) < 0
+		})
+		b.bids = append(b.bids, nil)
+		copy(b.bids[i+1:], b.bids[i:])
+		b.bids[i] = o
+	} else {
+		i := sort.Search(len(b.asks), func(i int) bool {
+			return b.asks[i].price.Cmp(o.price) > 0
+		})
+		b.asks = append(b.asks, nil)
+		copy(b.asks[i+1:], b.asks[i:])
+		b.asks[i] = o
+	}
+}
+
+func (b *book) remove(o *order) {
+	side := &b.asks
+	if o.typ == luno.OrderTypeBid {
+		side = &b.bids
+	}
+	for i, bo := range *side {
+		if bo == o {
+			*side = append((*side)[:i], (*side)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *book) entries(orders []*order) []luno.OrderBookEntry {
+	var l []luno.OrderBookEntry
+	for _, o := range orders {
+		l = append(l, luno.OrderBookEntry{Price: o.price, Volume: o.remain})
+	}
+	return l
+}
+
+// exchange is the state of the fake exchange. All methods must be called with
+// Server.mu held.
+type exchange struct {
+	now func() time.Time
+
+	nextID   int64
+	accounts []*account
+	orders   map[string]*order
+	books    map[string]*book
+	quotes   map[string]*luno.GetQuoteResponse
+
+	userTrades  []luno.Trade
+	withdrawals []*luno.Withdrawal
+	addresses   map[string][]*luno.ReceiveAddress
+
+	// onUpdate is called with every change to a book.
+	onUpdate func(pair string, u streaming.UpdateMessage)
+	seqs     map[string]int64
+}
+
+func newExchange(markets []Market) *exchange {
+	e := &exchange{
+		now:       time.Now,
+		orders:    make(map[string]*order),
+		books:     make(map[string]*book),
+		quotes:    make(map[string]*luno.GetQuoteResponse),
+		addresses: make(map[string][]*luno.ReceiveAddress),
+		seqs:      make(map[string]int64),
+	}
+	for _, m := range markets {
+		e.books[m.Pair] = &book{pair: m.Pair, base: m.Base, counter: m.Counter}
+		e.seqs[m.Pair] = 1
+	}
+	return e
+}
+
+func (e *exchange) newID(prefix string) string {
+	e.nextID++
+	return prefix + strconv.FormatInt(e.nextID, 10)
+}
+
+func (e *exchange) book(pair string) (*book, error) {
+	b, ok := e.books[pair]
+	if !ok {
+		return nil, apiError(luno.ErrInvalidPair, "Invalid currency pair")
+	}
+	return b, nil
+}
+
+// account returns the account with the given ID, or the default account for
+// the currency if id is empty. Default accounts are created on demand.
+func (e *exchange) account(id, currency string) (*account, error) {
+	for _, a := range e.accounts {
+		if id == "" && a.currency == currency {
+			return a, nil
+		}
+		if id != "" && a.id == id {
+			if currency != "" && a.currency != currency {
+				return nil, apiError(luno.ErrInvalidArguments,
+					"Account has the wrong currency")
+			}
+			return a, nil
+		}
+	}
+	if id != "" {
+		return nil, apiError(luno.ErrAccountNotFound, "Account not found")
+	}
+	return e.createAccount(currency, currency+" account"), nil
+}
+
+func (e *exchange) createAccount(currency, name string) *account {
+	a := &account{
+		id:       e.newID(""),
+		currency: currency,
+		name:     name,
+		balance:  decimal.Zero(),
+		reserved: decimal.Zero(),
+	}
+	e.accounts = append(e.accounts, a)
+	return a
+}
+
+// post records a transaction against a. Deltas are applied to the balance and
+// the available balance respectively.
+func (e *exchange) post(a *account, balanceDelta, availableDelta decimal.Decimal,
+	description string) {
+
+	a.balance = a.balance.Add(balanceDelta)
+	a.reserved = a.reserved.Add(balanceDelta).Sub(availableDelta)
+	a.txns = append(a.txns, luno.Transaction{
+		AccountId:      a.id,
+		Available:      a.available(),
+		AvailableDelta: availableDelta,
+		Balance:        a.balance,
+		BalanceDelta:   balanceDelta,
+		Currency:       a.currency,
+		Description:    description,
+		RowIndex:       int64(len(a.txns) + 1),
+		Timestamp:      luno.Time(e.now()),
+	})
+}
+
+func (e *exchange) reserve(a *account, amount decimal.Decimal, description string) error {
+	if a.available().Cmp(amount) < 0 {
+		return apiError(luno.ErrInsufficientBalance, "Insufficient balance")
+	}
+	e.post(a, decimal.Zero(), amount.Neg(), description)
+	return nil
+}
+
+// nextSeq returns the sequence number for the next update to the book. The
+// empty book starts at sequence 1, so that clients never see a zero sequence.
+func (e *exchange) nextSeq(pair string) int64 {
+	e.seqs[pair]++
+	return e.seqs[pair]
+}
+
+func (e *exchange) publish(pair string, u streaming.UpdateMessage) {
+	u.Sequence = e.nextSeq(pair)
+	u.Timestamp = e.now().UnixNano() / 1e6
+	if e.onUpdate != nil {
+		e.onUpdate(pair, u)
+	}
+}
+
+// placeLimitOrder matches a new limit order against the book and adds any
+// remainder to it. User orders reserve funds from their accounts.
+func (e *exchange) placeLimitOrder(o *order, postOnly bool) error {
+	b, err := e.book(o.pair)
+	if err != nil {
+		return err
+	}
+	if o.price.Sign() <= 0 || o.volume.Sign() <= 0 {
+		return apiError(luno.ErrInvalidArguments, "Price and volume must be positive")
+	}
+
+	if postOnly && e.crosses(b, o) {
+		return apiError(luno.ErrInvalidArguments, "Post-only order would trade")
+	}
+
+	if o.user {
+		if o.typ == luno.OrderTypeBid {
+			err = e.reserve(o.counterAcc, o.price.Mul(o.volume), "Reserved for order "+o.id)
+		} else {
+			err = e.reserve(o.baseAcc, o.volume, "Reserved for order "+o.id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	e.orders[o.id] = o
+
+	var u streaming.UpdateMessage
+	u.TradeUpdates = e.match(b, o, func(maker *order) decimal.Decimal {
+		if !e.crosses(b, o) {
+			return decimal.Zero()
+		}
+		return o.remain
+	})
+
+	if o.remain.Sign() > 0 {
+		b.insert(o)
+		u.CreateUpdate = &streaming.CreateUpdateMessage{
+			OrderID: o.id,
+			Type:    string(o.typ),
+			Price:   o.price,
+			Volume:  o.remain,
+		}
+	} else {
+		e.complete(o)
+	}
+
+	if u.CreateUpdate != nil || len(u.TradeUpdates) > 0 {
+		e.publish(o.pair, u)
+	}
+	return nil
+}
+
+// crosses reports whether o would trade with the best order on the other side
+// of the book.
+func (e *exchange) crosses(b *book, o *order) bool {
+	if o.typ == luno.OrderTypeBid {
+		return len(b.asks) > 0 && b.asks[0].price.Cmp(o.price) <= 0
+	}
+	return len(b.bids) > 0 && b.bids[0].price.Cmp(o.price) >= 0
+}
+
+// match trades the taker order against the other side of the book for as long
+// as want returns a positive base volume for the best maker order.
+func (e *exchange) match(b *book, taker *order,
+	want func(maker *order) decimal.Decimal) []*streaming.TradeUpdateMessage {
+
+	var trades []*streaming.TradeUpdateMessage
+	for {
+		side := b.asks
+		if taker.typ == luno.OrderTypeAsk {
+			side = b.bids
+		}
+		if len(side) == 0 {
+			return trades
+		}
+		maker := side[0]
+
+		volume := want(maker)
+		if volume.Sign() <= 0 {
+			return trades
+		}
+		if volume.Cmp(maker.remain) > 0 {
+			volume = maker.remain
+		}
+
+		e.trade(b, maker, taker, volume)
+		trades = append(trades, &streaming.TradeUpdateMessage{
+			Base:    volume,
+			Counter: maker.price.Mul(volume),
+			OrderID: maker.id,
+		})
+		if maker.remain.Sign() == 0 {
+			b.remove(maker)
+			e.complete(maker)
+		}
+	}
+}
+
+// trade executes volume at the maker's price.
+func (e *exchange) trade(b *book, maker, taker *order, volume decimal.Decimal) {
+	counter := maker.price.Mul(volume)
+	for _, o := range []*order{maker, taker} {
+		o.remain = o.remain.Sub(volume)
+		o.base = o.base.Add(volume)
+		o.counter = o.counter.Add(counter)
+		if o.user {
+			e.settle(o, volume, counter)
+		}
+	}
+
+	now := e.now()
+	isBuy := taker.typ == luno.OrderTypeBid
+	b.trades = append(b.trades, luno.Trade{
+		Base:      volume,
+		Counter:   counter,
+		IsBuy:     isBuy,
+		Pair:      b.pair,
+		Price:     maker.price,
+		Timestamp: luno.Time(now),
+		Volume:    volume,
+	})
+	b.lastTrade = maker.price
+}
+
+// settle moves funds for a user order which traded volume for counter.
+func (e *exchange) settle(o *order, volume, counter decimal.Decimal) {
+	desc := fmt.Sprintf("Trade %s", o.id)
+	if o.typ == luno.OrderTypeBid {
+		// Limit bids reserved funds at their limit price; any price
+		// improvement is released. Market orders reserve nothing, and have
+		// a zero price.
+		released := o.price.Mul(volume)
+		e.post(o.counterAcc, counter.Neg(), released.Sub(counter), desc)
+		e.post(o.baseAcc, volume, volume, desc)
+	} else {
+		released := volume
+		if o.price.Sign() == 0 {
+			released = decimal.Zero()
+		}
+		e.post(o.baseAcc, volume.Neg(), released.Sub(volume), desc)
+		e.post(o.counterAcc, counter, counter, desc)
+	}
+
+	e.userTrades = append(e.userTrades, luno.Trade{
+		Base:      volume,
+		Counter:   counter,
+		OrderId:   o.id,
+		Pair:      o.pair,
+		Price:     counter.Div(volume, volumeScale),
+		Timestamp: luno.Time(e.now()),
+		Type:      string(o.typ),
+		Volume:    volume,
+	})
+}
+
+// complete marks o as complete and releases any funds still reserved for it.
+func (e *exchange) complete(o *order) {
+	if o.state == luno.OrderStateComplete {
+		return
+	}
+	o.state = luno.OrderStateComplete
+	o.done = e.now()
+
+	if !o.user || o.remain.Sign() == 0 || o.price.Sign() == 0 {
+		return
+	}
+	desc := "Released for order " + o.id
+	if o.typ == luno.OrderTypeBid {
+		e.post(o.counterAcc, decimal.Zero(), o.price.Mul(o.remain), desc)
+	} else {
+		e.post(o.baseAcc, decimal.Zero(), o.remain, desc)
+	}
+}
+
+// placeMarketOrder trades a user market order against the book. Buys spend
+// counterVolume and sells sell baseVolume, as far as the book allows.
+func (e *exchange) placeMarketOrder(o *order, counterVolume decimal.Decimal) error {
+	b, err := e.book(o.pair)
+	if err != nil {
+		return err
+	}
+
+	var want func(maker *order) decimal.Decimal
+	if o.typ == luno.OrderTypeBid {
+		if counterVolume.Sign() <= 0 {
+			return apiError(luno.ErrInvalidArguments, "Counter volume must be positive")
+		}
+		if o.counterAcc.available().Cmp(counterVolume) < 0 {
+			return apiError(luno.ErrInsufficientBalance, "Insufficient balance")
+		}
+		left := counterVolume
+		want = func(maker *order) decimal.Decimal {
+			v := left.Div(maker.price, volumeScale)
+			if v.Cmp(maker.remain) > 0 {
+				v = maker.remain
+			}
+			left = left.Sub(maker.price.Mul(v))
+			return v
+		}
+	} else {
+		if o.volume.Sign() <= 0 {
+			return apiError(luno.ErrInvalidArguments, "Base volume must be positive")
+		}
+		if o.baseAcc.available().Cmp(o.volume) < 0 {
+			return apiError(luno.ErrInsufficientBalance, "Insufficient balance")
+		}
+		want = func(maker *order) decimal.Decimal {
+			return o.remain
+		}
+	}
+
+	// Market orders have no limit price, so nothing is reserved and
+	// settlement debits the balances directly.
+	o.price = decimal.Zero()
+	e.orders[o.id] = o
+
+	var u streaming.UpdateMessage
+	u.TradeUpdates = e.match(b, o, want)
+	e.complete(o)
+
+	if len(u.TradeUpdates) > 0 {
+		e.publish(o.pair, u)
+	}
+	return nil
+}
+
+// stopOrder cancels an order, removing it from the book.
+func (e *exchange) stopOrder(id string) error {
+	o, ok := e.orders[id]
+	if !ok {
+		return apiError(luno.ErrOrderNotFound, "Order not found")
+	}
+	if o.state == luno.OrderStateComplete {
+		return nil
+	}
+	b := e.books[o.pair]
+	b.remove(o)
+	e.complete(o)
+	e.publish(o.pair, streaming.UpdateMessage{
+		DeleteUpdate: &streaming.DeleteUpdateMessage{OrderID: o.id},
+	})
+	return nil
+}
+
+// bestPrice returns the price at which a quote of the given type can be
+// filled immediately.
+func (e *exchange) bestPrice(b *book, typ string) (decimal.Decimal, bool) {
+	side := b.asks
+	if typ == string(luno.OrderTypeSell) {
+		side = b.bids
+	}
+	if len(side) == 0 {
+		return decimal.Decimal{}, false
+	}
+	return side[0].price, true
+}
@@ -0,0 +1,713 @@
+package lunotest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// Page sizes of the real API.
+const (
+	maxListOrders       = 100
+	maxListTrades       = 100
+	maxListTransactions = 1000
+	maxListWithdrawals  = 1000
+)
+
+func (e *exchange) newOrder(pair string, typ luno.OrderType,
+	price, volume decimal.Decimal) *order {
+
+	return &order{
+		id:      e.newID("BX"),
+		pair:    pair,
+		typ:     typ,
+		price:   price,
+		volume:  volume,
+		remain:  volume,
+		base:    decimal.Zero(),
+		counter: decimal.Zero(),
+		created: e.now(),
+		state:   luno.OrderStatePending,
+	}
+}
+
+// userOrder returns a new order placed through the API, using the given
+// accounts or the default accounts for the pair.
+func (e *exchange) userOrder(r *http.Request, typ luno.OrderType,
+	price, volume decimal.Decimal) (*order, error) {
+
+	pair := r.FormValue("pair")
+	b, err := e.book(pair)
+	if err != nil {
+		return nil, err
+	}
+	baseAcc, err := e.account(r.FormValue("base_account_id"), b.base)
+	if err != nil {
+		return nil, err
+	}
+	counterAcc, err := e.account(r.FormValue("counter_account_id"), b.counter)
+	if err != nil {
+		return nil, err
+	}
+
+	o := e.newOrder(pair, typ, price, volume)
+	o.user = true
+	o.baseAcc = baseAcc
+	o.counterAcc = counterAcc
+	return o, nil
+}
+
+func (e *exchange) ticker(b *book) luno.Ticker {
+	t := luno.Ticker{
+		LastTrade: b.lastTrade,
+		Pair:      b.pair,
+		Timestamp: luno.Time(e.now()),
+	}
+	if len(b.asks) > 0 {
+		t.Ask = b.asks[0].price
+	}
+	if len(b.bids) > 0 {
+		t.Bid = b.bids[0].price
+	}
+	since := e.now().AddDate(0, 0, -1)
+	vol := decimal.Zero()
+	for _, tr := range b.trades {
+		if time.Time(tr.Timestamp).After(since) {
+			vol = vol.Add(tr.Volume)
+		}
+	}
+	t.Rolling24HourVolume = vol
+	return t
+}
+
+func (s *Server) getTicker(r *http.Request, _ string) (interface{}, error) {
+	b, err := s.ex.book(r.FormValue("pair"))
+	if err != nil {
+		return nil, err
+	}
+	t := s.ex.ticker(b)
+	return luno.GetTickerResponse{
+		Ask:                 t.Ask,
+		Bid:                 t.Bid,
+		LastTrade:           t.LastTrade,
+		Pair:                t.Pair,
+		Rolling24HourVolume: t.Rolling24HourVolume,
+		Timestamp:           t.Timestamp,
+	}, nil
+}
+
+func (s *Server) getTickers(r *http.Request, _ string) (interface{}, error) {
+	var res luno.GetTickersResponse
+	for _, b := range s.ex.books {
+		res.Tickers = append(res.Tickers, s.ex.ticker(b))
+	}
+	sort.Slice(res.Tickers, func(i, j int) bool {
+		return res.Tickers[i].Pair < res.Tickers[j].Pair
+	})
+	return res, nil
+}
+
+func (s *Server) getOrderBook(r *http.Request, _ string) (interface{}, error) {
+	b, err := s.ex.book(r.FormValue("pair"))
+	if err != nil {
+		return nil, err
+	}
+	return luno.GetOrderBookResponse{
+		Asks:      b.entries(b.asks),
+		Bids:      b.entries(b.bids),
+		Timestamp: s.ex.now().UnixNano() / 1e6,
+	}, nil
+}
+
+// tradesSince returns up to limit trades at or after since (Unix
+// milliseconds), oldest first.
+func tradesSince(trades []luno.Trade, pair string, since, limit int64) []luno.Trade {
+	var l []luno.Trade
+	for _, t := range trades {
+		if t.Pair != pair || time.Time(t.Timestamp).UnixNano()/1e6 < since {
+			continue
+		}
+		l = append(l, t)
+		if int64(len(l)) == limit {
+			break
+		}
+	}
+	return l
+}
+
+func (s *Server) listTrades(r *http.Request, _ string) (interface{}, error) {
+	b, err := s.ex.book(r.FormValue("pair"))
+	if err != nil {
+		return nil, err
+	}
+	since, err := formInt(r, "since")
+	if err != nil {
+		return nil, err
+	}
+
+	// The public API returns a page of trades after since, most recent
+	// first.
+	trades := tradesSince(b.trades, b.pair, since, maxListTrades)
+	for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+		trades[i], trades[j] = trades[j], trades[i]
+	}
+	return luno.ListTradesResponse{Trades: trades}, nil
+}
+
+func (s *Server) getBalances(r *http.Request, _ string) (interface{}, error) {
+	var res luno.GetBalancesResponse
+	for _, a := range s.ex.accounts {
+		res.Balance = append(res.Balance, a.toBalance())
+	}
+	return res, nil
+}
+
+func (s *Server) createAccount(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "currency", "name"); err != nil {
+		return nil, err
+	}
+	a := s.ex.createAccount(r.FormValue("currency"), r.FormValue("name"))
+	return luno.CreateAccountResponse{
+		Balance:  a.toBalance(),
+		Currency: a.currency,
+		Id:       a.id,
+		Name:     a.name,
+	}, nil
+}
+
+// rowRange resolves the min_row and max_row parameters of ListTransactions for
+// an account with n rows. Non-positive values wrap around the most recent row.
+func rowRange(min, max int64, n int) (int64, int64, error) {
+	if min <= 0 {
+		min += int64(n) + 1
+	}
+	if max <= 0 {
+		max += int64(n) + 1
+	}
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		return 0, 0, apiError(luno.ErrInvalidArguments, "Invalid row range")
+	}
+	if max-min > maxListTransactions {
+		return 0, 0, apiError(luno.ErrInvalidArguments, "Too many rows requested")
+	}
+	if max > int64(n)+1 {
+		max = int64(n) + 1
+	}
+	if min > max {
+		min = max
+	}
+	return min, max, nil
+}
+
+func (s *Server) listTransactions(r *http.Request, id string) (interface{}, error) {
+	a, err := s.ex.account(id, "")
+	if err != nil {
+		return nil, err
+	}
+	min, err := formInt(r, "min_row")
+	if err != nil {
+		return nil, err
+	}
+	max, err := formInt(r, "max_row")
+	if err != nil {
+		return nil, err
+	}
+	min, max, err = rowRange(min, max, len(a.txns))
+	if err != nil {
+		return nil, err
+	}
+
+	// Transactions are returned most recent first.
+	var txns []luno.Transaction
+	for i := max - 1; i >= min; i-- {
+		txns = append(txns, a.txns[i-1])
+	}
+	return luno.ListTransactionsResponse{
+		Balance:      a.toBalance(),
+		Currency:     a.currency,
+		Id:           a.id,
+		Name:         a.name,
+		Transactions: txns,
+	}, nil
+}
+
+func (s *Server) listPendingTransactions(r *http.Request, id string) (interface{}, error) {
+	a, err := s.ex.account(id, "")
+	if err != nil {
+		return nil, err
+	}
+	return luno.ListPendingTransactionsResponse{
+		Balance:  a.toBalance(),
+		Currency: a.currency,
+		Id:       a.id,
+		Name:     a.name,
+	}, nil
+}
+
+func (s *Server) getFeeInfo(r *http.Request, _ string) (interface{}, error) {
+	if _, err := s.ex.book(r.FormValue("pair")); err != nil {
+		return nil, err
+	}
+	return luno.GetFeeInfoResponse{
+		MakerFee:        "0.00",
+		TakerFee:        "0.00",
+		ThirtyDayVolume: "0",
+	}, nil
+}
+
+func (s *Server) postLimitOrder(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "pair", "type", "price", "volume"); err != nil {
+		return nil, err
+	}
+	typ := luno.OrderType(r.FormValue("type"))
+	if typ != luno.OrderTypeBid && typ != luno.OrderTypeAsk {
+		return nil, apiError(luno.ErrInvalidArguments, "Invalid order type")
+	}
+	price, err := formDecimal(r, "price")
+	if err != nil {
+		return nil, err
+	}
+	volume, err := formDecimal(r, "volume")
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := s.ex.userOrder(r, typ, price, volume)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ex.placeLimitOrder(o, r.FormValue("post_only") == "true"); err != nil {
+		return nil, err
+	}
+	return luno.PostLimitOrderResponse{OrderId: o.id}, nil
+}
+
+func (s *Server) postMarketOrder(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "pair", "type"); err != nil {
+		return nil, err
+	}
+
+	var typ luno.OrderType
+	switch luno.OrderType(r.FormValue("type")) {
+	case luno.OrderTypeBuy:
+		typ = luno.OrderTypeBid
+	case luno.OrderTypeSell:
+		typ = luno.OrderTypeAsk
+	default:
+		return nil, apiError(luno.ErrInvalidArguments, "Invalid order type")
+	}
+	baseVolume, err := formDecimal(r, "base_volume")
+	if err != nil {
+		return nil, err
+	}
+	counterVolume, err := formDecimal(r, "counter_volume")
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := s.ex.userOrder(r, typ, decimal.Zero(), baseVolume)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ex.placeMarketOrder(o, counterVolume); err != nil {
+		return nil, err
+	}
+	return luno.PostMarketOrderResponse{OrderId: o.id}, nil
+}
+
+// userOrderByID returns an order placed through the API.
+func (e *exchange) userOrderByID(id string) (*order, error) {
+	o, ok := e.orders[id]
+	if !ok || !o.user {
+		return nil, apiError(luno.ErrOrderNotFound, "Order not found")
+	}
+	return o, nil
+}
+
+func (s *Server) stopOrder(r *http.Request, _ string) (interface{}, error) {
+	o, err := s.ex.userOrderByID(r.FormValue("order_id"))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ex.stopOrder(o.id); err != nil {
+		return nil, err
+	}
+	return luno.StopOrderResponse{Success: true}, nil
+}
+
+func (s *Server) getOrder(r *http.Request, id string) (interface{}, error) {
+	o, err := s.ex.userOrderByID(id)
+	if err != nil {
+		return nil, err
+	}
+	lo := o.toOrder()
+	return luno.GetOrderResponse{
+		Base:               lo.Base,
+		CompletedTimestamp: lo.CompletedTimestamp,
+		Counter:            lo.Counter,
+		CreationTimestamp:  lo.CreationTimestamp,
+		LimitPrice:         lo.LimitPrice,
+		LimitVolume:        lo.LimitVolume,
+		OrderId:            lo.OrderId,
+		Pair:               lo.Pair,
+		State:              lo.State,
+		Type:               lo.Type,
+	}, nil
+}
+
+func (s *Server) listOrders(r *http.Request, _ string) (interface{}, error) {
+	createdBefore, err := formInt(r, "created_before")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := formInt(r, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListOrders {
+		limit = maxListOrders
+	}
+	pair := r.FormValue("pair")
+	state := luno.OrderState(r.FormValue("state"))
+
+	var orders []*order
+	for _, o := range s.ex.orders {
+		if !o.user ||
+			(pair != "" && o.pair != pair) ||
+			(state != "" && o.state != state) ||
+			(createdBefore > 0 && o.created.UnixNano()/1e6 >= createdBefore) {
+			continue
+		}
+		orders = append(orders, o)
+	}
+
+	// Most recent first. IDs are allocated in order of creation.
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].created.Equal(orders[j].created) {
+			return orders[i].created.After(orders[j].created)
+		}
+		return idLess(orders[j].id, orders[i].id)
+	})
+	if int64(len(orders)) > limit {
+		orders = orders[:limit]
+	}
+
+	var res luno.ListOrdersResponse
+	for _, o := range orders {
+		res.Orders = append(res.Orders, o.toOrder())
+	}
+	return res, nil
+}
+
+// idLess orders IDs allocated by newID.
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (s *Server) listUserTrades(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "pair"); err != nil {
+		return nil, err
+	}
+	since, err := formInt(r, "since")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := formInt(r, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListTrades {
+		limit = maxListTrades
+	}
+	trades := tradesSince(s.ex.userTrades, r.FormValue("pair"), since, limit)
+	return luno.ListUserTradesResponse{Trades: trades}, nil
+}
+
+func (s *Server) createQuote(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "pair", "type", "base_amount"); err != nil {
+		return nil, err
+	}
+	b, err := s.ex.book(r.FormValue("pair"))
+	if err != nil {
+		return nil, err
+	}
+	typ := r.FormValue("type")
+	if typ != string(luno.OrderTypeBuy) && typ != string(luno.OrderTypeSell) {
+		return nil, apiError(luno.ErrInvalidArguments, "Invalid quote type")
+	}
+	amount, err := formDecimal(r, "base_amount")
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() <= 0 {
+		return nil, apiError(luno.ErrInvalidArguments, "Amount must be positive")
+	}
+	price, ok := s.ex.bestPrice(b, typ)
+	if !ok {
+		return nil, apiError(luno.ErrInvalidArguments, "No liquidity for quote")
+	}
+
+	now := s.ex.now()
+	q := &luno.GetQuoteResponse{
+		BaseAmount:    amount,
+		CounterAmount: price.Mul(amount),
+		CreatedAt:     luno.Time(now),
+		ExpiresAt:     luno.Time(now.Add(quoteTTL)),
+		Id:            s.ex.newID(""),
+		Pair:          b.pair,
+		Type:          typ,
+	}
+	s.ex.quotes[q.Id] = q
+	return luno.CreateQuoteResponse(*q), nil
+}
+
+func (e *exchange) quote(id string) (*luno.GetQuoteResponse, error) {
+	q, ok := e.quotes[id]
+	if !ok {
+		return nil, apiError(luno.ErrInvalidArguments, "Quote not found")
+	}
+	return q, nil
+}
+
+func (s *Server) getQuote(r *http.Request, id string) (interface{}, error) {
+	q, err := s.ex.quote(id)
+	if err != nil {
+		return nil, err
+	}
+	return *q, nil
+}
+
+func (s *Server) exerciseQuote(r *http.Request, id string) (interface{}, error) {
+	q, err := s.ex.quote(id)
+	if err != nil {
+		return nil, err
+	}
+	if q.Exercised || q.Discarded || s.ex.now().After(time.Time(q.ExpiresAt)) {
+		return nil, apiError(luno.ErrInvalidArguments, "Quote is no longer valid")
+	}
+
+	b, err := s.ex.book(q.Pair)
+	if err != nil {
+		return nil, err
+	}
+	baseAcc, _ := s.ex.account("", b.base)
+	counterAcc, _ := s.ex.account("", b.counter)
+
+	from, to := counterAcc, baseAcc
+	fromAmount, toAmount := q.CounterAmount, q.BaseAmount
+	if q.Type == string(luno.OrderTypeSell) {
+		from, to = baseAcc, counterAcc
+		fromAmount, toAmount = q.BaseAmount, q.CounterAmount
+	}
+	if from.available().Cmp(fromAmount) < 0 {
+		return nil, apiError(luno.ErrInsufficientBalance, "Insufficient balance")
+	}
+	desc := "Quote " + q.Id
+	s.ex.post(from, fromAmount.Neg(), fromAmount.Neg(), desc)
+	s.ex.post(to, toAmount, toAmount, desc)
+
+	q.Exercised = true
+	return luno.ExerciseQuoteResponse(*q), nil
+}
+
+func (s *Server) discardQuote(r *http.Request, id string) (interface{}, error) {
+	q, err := s.ex.quote(id)
+	if err != nil {
+		return nil, err
+	}
+	if q.Exercised {
+		return nil, apiError(luno.ErrInvalidArguments, "Quote already exercised")
+	}
+	q.Discarded = true
+	return luno.DiscardQuoteResponse(*q), nil
+}
+
+func (e *exchange) withdrawal(id string) (*luno.Withdrawal, error) {
+	for _, w := range e.withdrawals {
+		if w.Id == id {
+			return w, nil
+		}
+	}
+	return nil, apiError(luno.ErrInvalidArguments, "Withdrawal not found")
+}
+
+// debit takes amount from the user's default account for a withdrawal or send.
+func (e *exchange) debit(currency string, amount decimal.Decimal,
+	typ, description string) (*luno.Withdrawal, error) {
+
+	if amount.Sign() <= 0 {
+		return nil, apiError(luno.ErrInvalidArguments, "Amount must be positive")
+	}
+	a, err := e.account("", currency)
+	if err != nil {
+		return nil, err
+	}
+	if a.available().Cmp(amount) < 0 {
+		return nil, apiError(luno.ErrInsufficientBalance, "Insufficient balance")
+	}
+
+	w := &luno.Withdrawal{
+		Amount:    amount,
+		CreatedAt: luno.Time(e.now()),
+		Currency:  currency,
+		Fee:       decimal.Zero(),
+		Id:        e.newID(""),
+		Status:    "PENDING",
+		Type:      typ,
+	}
+	e.post(a, amount.Neg(), amount.Neg(), description+" "+w.Id)
+	e.withdrawals = append(e.withdrawals, w)
+	return w, nil
+}
+
+func (s *Server) listWithdrawals(r *http.Request, _ string) (interface{}, error) {
+	beforeID, err := formInt(r, "before_id")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := formInt(r, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListWithdrawals {
+		limit = maxListWithdrawals
+	}
+
+	// Most recent first. IDs are allocated in order of creation.
+	var res luno.ListWithdrawalsResponse
+	for i := len(s.ex.withdrawals) - 1; i >= 0; i-- {
+		w := s.ex.withdrawals[i]
+		if beforeID > 0 && !idLess(w.Id, strconv.FormatInt(beforeID, 10)) {
+			continue
+		}
+		if int64(len(res.Withdrawals)) >= limit {
+			break
+		}
+		res.Withdrawals = append(res.Withdrawals, *w)
+	}
+	return res, nil
+}
+
+func (s *Server) createWithdrawal(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "type", "amount"); err != nil {
+		return nil, err
+	}
+	amount, err := formDecimal(r, "amount")
+	if err != nil {
+		return nil, err
+	}
+	// Withdrawal types are prefixed with the currency, e.g. ZAR_EFT.
+	typ := r.FormValue("type")
+	currency := strings.SplitN(typ, "_", 2)[0]
+
+	w, err := s.ex.debit(currency, amount, typ, "Withdrawal")
+	if err != nil {
+		return nil, err
+	}
+	return luno.CreateWithdrawalResponse(*w), nil
+}
+
+func (s *Server) getWithdrawal(r *http.Request, id string) (interface{}, error) {
+	w, err := s.ex.withdrawal(id)
+	if err != nil {
+		return nil, err
+	}
+	return luno.GetWithdrawalResponse(*w), nil
+}
+
+func (s *Server) cancelWithdrawal(r *http.Request, id string) (interface{}, error) {
+	w, err := s.ex.withdrawal(id)
+	if err != nil {
+		return nil, err
+	}
+	if w.Status != "PENDING" {
+		return nil, apiError(luno.ErrInvalidArguments, "Withdrawal is not pending")
+	}
+	a, _ := s.ex.account("", w.Currency)
+	s.ex.post(a, w.Amount, w.Amount, "Cancelled withdrawal "+w.Id)
+	w.Status = "CANCELLED"
+	return luno.CancelWithdrawalResponse(*w), nil
+}
+
+func (s *Server) send(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "address", "amount", "currency"); err != nil {
+		return nil, err
+	}
+	amount, err := formDecimal(r, "amount")
+	if err != nil {
+		return nil, err
+	}
+	currency := r.FormValue("currency")
+	w, err := s.ex.debit(currency, amount, currency+"_SEND", "Sent to "+r.FormValue("address"))
+	if err != nil {
+		return nil, err
+	}
+	return luno.SendResponse{Success: true, WithdrawalId: w.Id}, nil
+}
+
+// fundingAddresses returns the receive addresses for asset, allocating a
+// default address if there are none.
+func (e *exchange) fundingAddresses(asset string) ([]*luno.ReceiveAddress, error) {
+	if asset == "" {
+		return nil, apiError(luno.ErrInvalidArguments, "Missing asset")
+	}
+	if len(e.addresses[asset]) == 0 {
+		e.newFundingAddress(asset, "")
+	}
+	return e.addresses[asset], nil
+}
+
+func (e *exchange) newFundingAddress(asset, name string) *luno.ReceiveAddress {
+	a, _ := e.account("", asset)
+	ra := &luno.ReceiveAddress{
+		AccountId:        a.id,
+		Address:          "lunotest" + strings.ToLower(asset) + e.newID(""),
+		Asset:            asset,
+		AssignedAt:       luno.Time(e.now()),
+		Name:             name,
+		ReceiveFee:       decimal.Zero(),
+		TotalReceived:    decimal.Zero(),
+		TotalUnconfirmed: decimal.Zero(),
+	}
+	e.addresses[asset] = append(e.addresses[asset], ra)
+	return ra
+}
+
+func (s *Server) getFundingAddress(r *http.Request, _ string) (interface{}, error) {
+	addrs, err := s.ex.fundingAddresses(r.FormValue("asset"))
+	if err != nil {
+		return nil, err
+	}
+	ra := addrs[0]
+	if a := r.FormValue("address"); a != "" {
+		ra = nil
+		for _, x := range addrs {
+			if x.Address == a {
+				ra = x
+			}
+		}
+		if ra == nil {
+			return nil, apiError(luno.ErrInvalidArguments, "Address not found")
+		}
+	}
+	return luno.GetFundingAddressResponse(*ra), nil
+}
+
+func (s *Server) createFundingAddress(r *http.Request, _ string) (interface{}, error) {
+	if err := requireForm(r, "asset"); err != nil {
+		return nil, err
+	}
+	ra := s.ex.newFundingAddress(r.FormValue("asset"), r.FormValue("name"))
+	return luno.CreateFundingAddressResponse(*ra), nil
+}
@@ -0,0 +1,372 @@
+// Package lunotest provides an in-memory fake Luno exchange for integration
+// tests.
+//
+// The fake implements the REST endpoints used by luno.Client and the
+// websocket stream used by the streaming package. Orders placed through the
+// API are matched against the order book with price-time priority and settle
+// against the user's accounts. Tests can fund accounts with Fund and add
+// liquidity from other market participants with AddOrder.
+//
+// Example:
+//
+//	srv := lunotest.NewServer("XBTZAR")
+//	defer srv.Close()
+//
+//	srv.Fund("ZAR", decimal.NewFromInt64(1000))
+//	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, price, volume)
+//
+//	cl := srv.Client()
+//	res, err := cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{...})
+package lunotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// DefaultKeyID and DefaultKeySecret are the credentials accepted by the
+	// server.
+	DefaultKeyID     = "lunotest_key_id"
+	DefaultKeySecret = "lunotest_key_secret"
+)
+
+// quoteTTL is how long a quote can be exercised after it was created.
+const quoteTTL = 5 * time.Minute
+
+// Server is a fake Luno exchange serving the REST and streaming APIs.
+type Server struct {
+	srv *httptest.Server
+
+	mu     sync.Mutex
+	ex     *exchange
+	routes []route
+	subs   map[*subscriber]bool
+}
+
+// Market is an order book served by a Server: its pair, e.g. "USDCZAR", and
+// the currencies it trades, e.g. "USDC" and "ZAR".
+type Market struct {
+	Pair    string
+	Base    string
+	Counter string
+}
+
+// NewServer starts a fake exchange with order books for the given pairs, e.g.
+// "XBTZAR". Each pair must be a three letter base currency followed by a three
+// letter counter currency; use NewMarketServer for other pairs. It panics if a
+// pair is invalid. The caller must call Close when done.
+func NewServer(pairs ...string) *Server {
+	markets := make([]Market, 0, len(pairs))
+	for _, p := range pairs {
+		if len(p) != 6 {
+			panic("lunotest: pair " + strconv.Quote(p) +
+				" is not two three letter currencies, use NewMarketServer")
+		}
+		markets = append(markets, Market{Pair: p, Base: p[:3], Counter: p[3:]})
+	}
+	s, err := NewMarketServer(markets...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewMarketServer is like NewServer, but takes the currencies of each market
+// explicitly. It returns an error if a market's pair or currencies are empty
+// or its pair is repeated.
+func NewMarketServer(markets ...Market) (*Server, error) {
+	seen := make(map[string]bool)
+	for _, m := range markets {
+		if m.Pair == "" || m.Base == "" || m.Counter == "" || m.Base == m.Counter {
+			return nil, fmt.Errorf("lunotest: invalid market %+v", m)
+		}
+		if seen[m.Pair] {
+			return nil, fmt.Errorf("lunotest: repeated pair %s", m.Pair)
+		}
+		seen[m.Pair] = true
+	}
+
+	s := &Server{
+		ex:   newExchange(markets),
+		subs: make(map[*subscriber]bool),
+	}
+	s.ex.onUpdate = s.broadcast
+	s.routes = s.makeRoutes()
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/1/stream/", websocket.Handler(s.serveStream))
+	mux.HandleFunc("/", s.serveAPI)
+	s.srv = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Close shuts down the server and disconnects all streaming clients.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for sub := range s.subs {
+		sub.close()
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// URL returns the base URL of the REST API.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// WebsocketURL returns the base URL of the streaming API.
+func (s *Server) WebsocketURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Client returns a client which talks to the server with valid credentials.
+func (s *Server) Client() *luno.Client {
+	cl := luno.NewClient()
+	cl.SetBaseURL(s.srv.URL)
+	cl.SetAuth(DefaultKeyID, DefaultKeySecret)
+	return cl
+}
+
+// SetClock replaces the clock used for timestamps, e.g. to test expiry.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ex.now = now
+}
+
+// Fund credits the user's default account for currency, creating it if
+// necessary, and returns the account ID.
+func (s *Server) Fund(currency string, amount decimal.Decimal) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, _ := s.ex.account("", currency)
+	s.ex.post(a, amount, amount, "Deposit")
+	return a.id
+}
+
+// AddOrder adds a limit order from another market participant to the book and
+// returns its ID. It may trade with existing orders, including the user's. typ
+// must be luno.OrderTypeBid or luno.OrderTypeAsk.
+func (s *Server) AddOrder(pair string, typ luno.OrderType,
+	price, volume decimal.Decimal) (string, error) {
+
+	if typ != luno.OrderTypeBid && typ != luno.OrderTypeAsk {
+		return "", apiError(luno.ErrInvalidArguments, "Invalid order type")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := s.ex.newOrder(pair, typ, price, volume)
+	if err := s.ex.placeLimitOrder(o, false); err != nil {
+		return "", err
+	}
+	return o.id, nil
+}
+
+// RemoveOrder stops any order, e.g. one added with AddOrder.
+func (s *Server) RemoveOrder(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ex.stopOrder(id)
+}
+
+// CompleteWithdrawal marks a pending withdrawal as completed.
+func (s *Server) CompleteWithdrawal(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, err := s.ex.withdrawal(id)
+	if err != nil {
+		return err
+	}
+	w.Status = "COMPLETED"
+	return nil
+}
+
+type handler func(r *http.Request, id string) (interface{}, error)
+
+type route struct {
+	method  string
+	pattern []string
+	auth    bool
+	h       handler
+}
+
+func (s *Server) makeRoutes() []route {
+	r := func(method, pattern string, auth bool, h handler) route {
+		return route{method, strings.Split(pattern, "/"), auth, h}
+	}
+	return []route{
+		r("GET", "/api/1/ticker", false, s.getTicker),
+		r("GET", "/api/1/tickers", false, s.getTickers),
+		r("GET", "/api/1/orderbook", false, s.getOrderBook),
+		r("GET", "/api/1/trades", false, s.listTrades),
+
+		r("GET", "/api/1/balance", true, s.getBalances),
+		r("POST", "/api/1/accounts", true, s.createAccount),
+		r("GET", "/api/1/accounts/{id}/transactions", true, s.listTransactions),
+		r("GET", "/api/1/accounts/{id}/pending", true, s.listPendingTransactions),
+		r("GET", "/api/1/fee_info", true, s.getFeeInfo),
+
+		r("POST", "/api/1/postorder", true, s.postLimitOrder),
+		r("POST", "/api/1/marketorder", true, s.postMarketOrder),
+		r("POST", "/api/1/stoporder", true, s.stopOrder),
+		r("GET", "/api/1/orders/{id}", true, s.getOrder),
+		r("GET", "/api/1/listorders", true, s.listOrders),
+		r("GET", "/api/1/listtrades", true, s.listUserTrades),
+
+		r("POST", "/api/1/quotes", true, s.createQuote),
+		r("GET", "/api/1/quotes/{id}", true, s.getQuote),
+		r("PUT", "/api/1/quotes/{id}", true, s.exerciseQuote),
+		r("DELETE", "/api/1/quotes/{id}", true, s.discardQuote),
+
+		r("GET", "/api/1/withdrawals", true, s.listWithdrawals),
+		r("POST", "/api/1/withdrawals", true, s.createWithdrawal),
+		r("GET", "/api/1/withdrawals/{id}", true, s.getWithdrawal),
+		r("DELETE", "/api/1/withdrawals/{id}", true, s.cancelWithdrawal),
+		r("POST", "/api/1/send", true, s.send),
+
+		r("GET", "/api/1/funding_address", true, s.getFundingAddress),
+		r("POST", "/api/1/funding_address", true, s.createFundingAddress),
+	}
+}
+
+// match returns the route for the request and the value of its {id}
+// parameter, if any.
+func (s *Server) match(r *http.Request) (route, string, bool) {
+	parts := strings.Split(r.URL.Path, "/")
+	for _, rt := range s.routes {
+		if rt.method != r.Method || len(rt.pattern) != len(parts) {
+			continue
+		}
+		var id string
+		ok := true
+		for i, p := range rt.pattern {
+			if p == "{id}" {
+				id = parts[i]
+			} else if p != parts[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return rt, id, true
+		}
+	}
+	return route{}, "", false
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	rt, id, ok := s.match(r)
+	if !ok {
+		writeError(w, &apiErr{status: http.StatusNotFound, msg: "Not found"})
+		return
+	}
+
+	if rt.auth {
+		keyID, keySecret, ok := r.BasicAuth()
+		if !ok || keyID != DefaultKeyID || keySecret != DefaultKeySecret {
+			writeError(w, &apiErr{
+				status: http.StatusUnauthorized,
+				code:   luno.ErrUnauthorised.Code,
+				msg:    "Unauthorised",
+			})
+			return
+		}
+	}
+
+	// DELETE bodies aren't parsed by ParseForm.
+	if r.Method == http.MethodDelete {
+		r.Method = http.MethodPost
+		r.ParseForm()
+		r.Method = http.MethodDelete
+	}
+
+	s.mu.Lock()
+	res, err := rt.h(r, id)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+type apiErr struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *apiErr) Error() string {
+	return e.msg
+}
+
+// apiError returns an error with the code of the given sentinel error.
+func apiError(sentinel *luno.Error, msg string) error {
+	status := http.StatusBadRequest
+	switch sentinel {
+	case luno.ErrAccountNotFound, luno.ErrOrderNotFound:
+		status = http.StatusNotFound
+	}
+	return &apiErr{status: status, code: sentinel.Code, msg: msg}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiErr)
+	if !ok {
+		e = &apiErr{status: http.StatusInternalServerError, msg: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(luno.Error{Code: e.code, Message: e.msg})
+}
+
+func formDecimal(r *http.Request, key string) (decimal.Decimal, error) {
+	v := r.FormValue(key)
+	if v == "" {
+		return decimal.Zero(), nil
+	}
+	d, err := decimal.NewFromString(v)
+	if err != nil {
+		return decimal.Decimal{}, apiError(luno.ErrInvalidArguments, "Invalid "+key)
+	}
+	return d, nil
+}
+
+func formInt(r *http.Request, key string) (int64, error) {
+	v := r.FormValue(key)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, apiError(luno.ErrInvalidArguments, "Invalid "+key)
+	}
+	return i, nil
+}
+
+func requireForm(r *http.Request, keys ...string) error {
+	for _, k := range keys {
+		if r.FormValue(k) == "" {
+			return apiError(luno.ErrInvalidArguments, "Missing "+k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+package lunotest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"github.com/luno/luno-go/lunotest"
+	"github.com/luno/luno-go/streaming"
+)
+
+func dec(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func balances(t *testing.T, cl *luno.Client) map[string]luno.AccountBalance {
+	res, err := cl.GetBalances(context.Background(), &luno.GetBalancesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]luno.AccountBalance)
+	for _, b := range res.Balance {
+		m[b.Asset] = b
+	}
+	return m
+}
+
+func expectDecimal(t *testing.T, name string, exp string, act decimal.Decimal) {
+	t.Helper()
+	if act.Cmp(dec(t, exp)) != 0 {
+		t.Errorf("Expected %s to be %s, got %s", name, exp, act)
+	}
+}
+
+func TestLimitOrderMatching(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("ZAR", dec(t, "10000"))
+
+	if _, err := srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1000"), dec(t, "2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1100"), dec(t, "2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Crosses both asks and rests the remainder.
+	res, err := cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{
+		Pair:   "XBTZAR",
+		Type:   luno.OrderTypeBid,
+		Price:  dec(t, "1100"),
+		Volume: dec(t, "5"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := cl.GetOrder(ctx, &luno.GetOrderRequest{Id: res.OrderId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDecimal(t, "base", "4", o.Base)
+	expectDecimal(t, "counter", "4200", o.Counter)
+	if o.State != string(luno.OrderStatePending) {
+		t.Errorf("Expected order to be pending, got %s", o.State)
+	}
+
+	b := balances(t, cl)
+	expectDecimal(t, "XBT balance", "4", b["XBT"].Balance)
+	expectDecimal(t, "ZAR balance", "5800", b["ZAR"].Balance)
+	expectDecimal(t, "ZAR reserved", "1100", b["ZAR"].Reserved)
+
+	book, err := cl.GetOrderBook(ctx, &luno.GetOrderBookRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Asks) != 0 || len(book.Bids) != 1 {
+		t.Fatalf("Expected 0 asks and 1 bid, got %v", book)
+	}
+
+	if _, err := cl.StopOrder(ctx, &luno.StopOrderRequest{OrderId: res.OrderId}); err != nil {
+		t.Fatal(err)
+	}
+	b = balances(t, cl)
+	expectDecimal(t, "ZAR reserved", "0", b["ZAR"].Reserved)
+
+	trades, err := cl.ListUserTrades(ctx, &luno.ListUserTradesRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades.Trades) != 2 {
+		t.Errorf("Expected 2 trades, got %d", len(trades.Trades))
+	}
+}
+
+func TestInsufficientBalance(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	_, err := srv.Client().PostLimitOrder(context.Background(), &luno.PostLimitOrderRequest{
+		Pair:   "XBTZAR",
+		Type:   luno.OrderTypeAsk,
+		Price:  dec(t, "1000"),
+		Volume: dec(t, "1"),
+	})
+	if !errors.Is(err, luno.ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+}
+
+func TestInvalidPair(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	_, err := srv.Client().GetTicker(context.Background(), &luno.GetTickerRequest{Pair: "XBTUSD"})
+	if !errors.Is(err, luno.ErrInvalidPair) {
+		t.Errorf("Expected ErrInvalidPair, got %v", err)
+	}
+}
+
+func TestUnauthorised(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	cl := luno.NewClient()
+	cl.SetBaseURL(srv.URL())
+	cl.SetAuth("wrong", "wrong")
+
+	_, err := cl.GetBalances(context.Background(), &luno.GetBalancesRequest{})
+	if !errors.Is(err, luno.ErrUnauthorised) {
+		t.Errorf("Expected ErrUnauthorised, got %v", err)
+	}
+}
+
+func TestMarketOrder(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("XBT", dec(t, "3"))
+	srv.AddOrder("XBTZAR", luno.OrderTypeBid, dec(t, "900"), dec(t, "1"))
+	srv.AddOrder("XBTZAR", luno.OrderTypeBid, dec(t, "800"), dec(t, "5"))
+
+	_, err := cl.PostMarketOrder(ctx, &luno.PostMarketOrderRequest{
+		Pair:       "XBTZAR",
+		Type:       luno.OrderTypeSell,
+		BaseVolume: dec(t, "2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := balances(t, cl)
+	expectDecimal(t, "XBT balance", "1", b["XBT"].Balance)
+	expectDecimal(t, "XBT reserved", "0", b["XBT"].Reserved)
+	expectDecimal(t, "ZAR balance", "1700", b["ZAR"].Balance)
+
+	ticker, err := cl.GetTicker(ctx, &luno.GetTickerRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDecimal(t, "last trade", "800", ticker.LastTrade)
+	expectDecimal(t, "bid", "800", ticker.Bid)
+}
+
+func TestListTransactions(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	id := srv.Fund("ZAR", dec(t, "100"))
+	srv.Fund("ZAR", dec(t, "50"))
+	cl := srv.Client()
+
+	res, err := cl.ListTransactions(context.Background(), &luno.ListTransactionsRequest{
+		Id:     id,
+		MinRow: 1,
+		MaxRow: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(res.Transactions))
+	}
+	if res.Transactions[0].RowIndex != 2 || res.Transactions[1].RowIndex != 1 {
+		t.Errorf("Expected rows 2 and 1, got %v", res.Transactions)
+	}
+	expectDecimal(t, "balance", "150", res.Transactions[0].Balance)
+}
+
+func TestWithdrawals(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("ZAR", dec(t, "100"))
+
+	w, err := cl.CreateWithdrawal(ctx, &luno.CreateWithdrawalRequest{
+		Type:   "ZAR_EFT",
+		Amount: dec(t, "60"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDecimal(t, "ZAR balance", "40", balances(t, cl)["ZAR"].Balance)
+
+	if _, err := cl.CancelWithdrawal(ctx, &luno.CancelWithdrawalRequest{Id: w.Id}); err != nil {
+		t.Fatal(err)
+	}
+	expectDecimal(t, "ZAR balance", "100", balances(t, cl)["ZAR"].Balance)
+
+	got, err := cl.GetWithdrawal(ctx, &luno.GetWithdrawalRequest{Id: w.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "CANCELLED" {
+		t.Errorf("Expected withdrawal to be cancelled, got %s", got.Status)
+	}
+}
+
+func TestQuotes(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("ZAR", dec(t, "1000"))
+	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "500"), dec(t, "10"))
+
+	q, err := cl.CreateQuote(ctx, &luno.CreateQuoteRequest{
+		Pair:       "XBTZAR",
+		Type:       "BUY",
+		BaseAmount: dec(t, "1.5"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDecimal(t, "counter amount", "750", q.CounterAmount)
+
+	if _, err := cl.ExerciseQuote(ctx, &luno.ExerciseQuoteRequest{Id: q.Id}); err != nil {
+		t.Fatal(err)
+	}
+	b := balances(t, cl)
+	expectDecimal(t, "XBT balance", "1.5", b["XBT"].Balance)
+	expectDecimal(t, "ZAR balance", "250", b["ZAR"].Balance)
+
+	if _, err := cl.ExerciseQuote(ctx, &luno.ExerciseQuoteRequest{Id: q.Id}); err == nil {
+		t.Errorf("Expected quote not to be exercised twice")
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1000"), dec(t, "1"))
+
+	updates := make(chan streaming.UpdateMessage, 10)
+	c, err := streaming.Dial(lunotest.DefaultKeyID, lunotest.DefaultKeySecret,
+		"XBTZAR", streaming.WithHost(srv.WebsocketURL()),
+		streaming.WithUpdateCallback(func(u streaming.UpdateMessage) {
+			updates <- u
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	waitForSeq := func(seq int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if s, _, _ := c.GetSnapshot(); s >= seq {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for sequence %d", seq)
+	}
+
+	seq, _, _, _ := srv.Snapshot("XBTZAR")
+	waitForSeq(seq)
+
+	srv.AddOrder("XBTZAR", luno.OrderTypeBid, dec(t, "900"), dec(t, "2"))
+	srv.AddOrder("XBTZAR", luno.OrderTypeBid, dec(t, "1000"), dec(t, "0.5"))
+	waitForSeq(seq + 2)
+
+	expSeq, expBids, expAsks, _ := srv.Snapshot("XBTZAR")
+	actSeq, actBids, actAsks := c.GetSnapshot()
+	if expSeq != actSeq || len(expBids) != len(actBids) || len(expAsks) != len(actAsks) {
+		t.Fatalf("Expected %d %v %v, got %d %v %v",
+			expSeq, expBids, expAsks, actSeq, actBids, actAsks)
+	}
+	expectDecimal(t, "ask volume", "0.5", actAsks[0].Volume)
+	if n := len(updates); n != 2 {
+		t.Errorf("Expected 2 updates, got %d", n)
+	}
+}
+
+func TestIterTransactions(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	var id string
+	for i := 0; i < 1500; i++ {
+		id = srv.Fund("ZAR", dec(t, "1"))
+	}
+
+	it := srv.Client().IterTransactions(context.Background(), id, luno.IterOptions{Reverse: true})
+	var n int64
+	for it.Next() {
+		if row := it.Transaction().RowIndex; row != 1500-n {
+			t.Fatalf("Expected row %d, got %d", 1500-n, row)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1500 {
+		t.Errorf("Expected 1500 transactions, got %d", n)
+	}
+}
+
+func TestMarketServer(t *testing.T) {
+	srv, err := lunotest.NewMarketServer(lunotest.Market{Pair: "USDCZAR", Base: "USDC", Counter: "ZAR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("USDC", dec(t, "10"))
+	if _, err := srv.AddOrder("USDCZAR", luno.OrderTypeBid, dec(t, "18"), dec(t, "10")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{
+		Pair:   "USDCZAR",
+		Type:   luno.OrderTypeAsk,
+		Price:  dec(t, "18"),
+		Volume: dec(t, "4"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := balances(t, cl)
+	expectDecimal(t, "USDC balance", "6", b["USDC"].Balance)
+	expectDecimal(t, "ZAR balance", "72", b["ZAR"].Balance)
+}
+
+func TestMarketServerInvalid(t *testing.T) {
+	for _, markets := range [][]lunotest.Market{
+		{{Pair: "XBTZAR", Base: "XBT"}},
+		{{Pair: "", Base: "XBT", Counter: "ZAR"}},
+		{{Pair: "XBTZAR", Base: "XBT", Counter: "ZAR"}, {Pair: "XBTZAR", Base: "XBT", Counter: "ZAR"}},
+	} {
+		if _, err := lunotest.NewMarketServer(markets...); err == nil {
+			t.Errorf("Expected error for %+v", markets)
+		}
+	}
+}
+
+func TestNewServerInvalidPair(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for invalid pair")
+		}
+	}()
+	lunotest.NewServer("XB")
+}
+
+func TestAddOrderInvalidType(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	for _, typ := range []luno.OrderType{luno.OrderTypeBuy, luno.OrderTypeSell, ""} {
+		_, err := srv.AddOrder("XBTZAR", typ, dec(t, "1000"), dec(t, "1"))
+		if err == nil {
+			t.Errorf("Expected error for %q", typ)
+		}
+	}
+}
+
+func TestIterUserTradesIdenticalFills(t *testing.T) {
+	srv := lunotest.NewServer("XBTZAR")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cl := srv.Client()
+	srv.Fund("ZAR", dec(t, "2000"))
+	for i := 0; i < 2; i++ {
+		if _, err := srv.AddOrder("XBTZAR", luno.OrderTypeAsk, dec(t, "1000"), dec(t, "1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One bid fills both asks at the same price and time.
+	_, err := cl.PostLimitOrder(ctx, &luno.PostLimitOrderRequest{
+		Pair:   "XBTZAR",
+		Type:   luno.OrderTypeBid,
+		Price:  dec(t, "1000"),
+		Volume: dec(t, "2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := cl.ListUserTrades(ctx, &luno.ListUserTradesRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for it := cl.IterUserTrades(ctx, "XBTZAR", luno.IterOptions{}); it.Next(); {
+		n++
+	}
+	if len(res.Trades) != 2 || n != 2 {
+		t.Errorf("Expected 2 trades, got %d listed and %d iterated", len(res.Trades), n)
+	}
+}
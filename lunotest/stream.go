@@ -0,0 +1,160 @@
+package lunotest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	luno "github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+	"github.com/luno/luno-go/streaming"
+	"golang.org/x/net/websocket"
+)
+
+// subscriberBuffer is the number of updates buffered per streaming client.
+// Clients which fall further behind are disconnected, like on the real
+// exchange.
+const subscriberBuffer = 1024
+
+type streamOrder struct {
+	ID     string          `json:"id"`
+	Price  decimal.Decimal `json:"price"`
+	Volume decimal.Decimal `json:"volume"`
+}
+
+type streamSnapshot struct {
+	Sequence  string        `json:"sequence"`
+	Asks      []streamOrder `json:"asks"`
+	Bids      []streamOrder `json:"bids"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+type streamCredentials struct {
+	APIKeyID     string `json:"api_key_id"`
+	APIKeySecret string `json:"api_key_secret"`
+}
+
+type subscriber struct {
+	pair    string
+	updates chan streaming.UpdateMessage
+	ws      *websocket.Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+func (sub *subscriber) close() {
+	sub.once.Do(func() {
+		close(sub.done)
+		sub.ws.Close()
+	})
+}
+
+// broadcast sends an update to all subscribers of the pair. It is called with
+// s.mu held.
+func (s *Server) broadcast(pair string, u streaming.UpdateMessage) {
+	for sub := range s.subs {
+		if sub.pair != pair {
+			continue
+		}
+		select {
+		case sub.updates <- u:
+		default:
+			sub.close()
+			delete(s.subs, sub)
+		}
+	}
+}
+
+func (s *Server) serveStream(ws *websocket.Conn) {
+	defer ws.Close()
+
+	pair := strings.TrimPrefix(ws.Request().URL.Path, "/api/1/stream/")
+
+	var cred streamCredentials
+	if err := websocket.JSON.Receive(ws, &cred); err != nil {
+		return
+	}
+	if cred.APIKeyID != DefaultKeyID || cred.APIKeySecret != DefaultKeySecret {
+		return
+	}
+
+	sub := &subscriber{
+		pair:    pair,
+		updates: make(chan streaming.UpdateMessage, subscriberBuffer),
+		ws:      ws,
+		done:    make(chan struct{}),
+	}
+
+	// Take the snapshot and subscribe atomically so that no updates are
+	// missed in between.
+	s.mu.Lock()
+	b, err := s.ex.book(pair)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	snap := streamSnapshot{
+		Sequence:  strconv.FormatInt(s.ex.seqs[pair], 10),
+		Asks:      streamOrders(b.asks),
+		Bids:      streamOrders(b.bids),
+		Timestamp: s.ex.now().UnixNano() / 1e6,
+	}
+	s.subs[sub] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+		sub.close()
+	}()
+
+	if err := websocket.JSON.Send(ws, snap); err != nil {
+		return
+	}
+
+	// Discard client messages, which are only keep-alives, and notice when
+	// the client goes away.
+	go func() {
+		defer sub.close()
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case u := <-sub.updates:
+			if err := websocket.JSON.Send(ws, u); err != nil {
+				return
+			}
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+func streamOrders(orders []*order) []streamOrder {
+	l := make([]streamOrder, 0, len(orders))
+	for _, o := range orders {
+		l = append(l, streamOrder{ID: o.id, Price: o.price, Volume: o.remain})
+	}
+	return l
+}
+
+// Snapshot returns the current order book for pair as seen by streaming
+// clients, with its sequence number.
+func (s *Server) Snapshot(pair string) (int64, []luno.OrderBookEntry, []luno.OrderBookEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.ex.book(pair)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return s.ex.seqs[pair], b.entries(b.bids), b.entries(b.asks), nil
+}
@@ -0,0 +1,45 @@
+package luno
+
+import "context"
+
+// Call describes an API call made by the client.
+type Call struct {
+	// Operation is the name of the client method, e.g. "PostLimitOrder".
+	Operation string
+
+	// Method and Path identify the endpoint, e.g. "POST" and
+	// "/api/1/postorder".
+	Method string
+	Path   string
+
+	// Auth is true for calls which require authentication.
+	Auth bool
+
+	// Request is the typed request struct, e.g. *PostLimitOrderRequest.
+	Request interface{}
+}
+
+// Handler makes an API call. On success, the response has been decoded into
+// res, which is the typed response struct, e.g. *PostLimitOrderResponse.
+type Handler func(ctx context.Context, call *Call, res interface{}) error
+
+// Middleware wraps a Handler to observe or change the behaviour of API calls.
+// It can be used for tracing, metrics, audit logging or policy checks.
+//
+// A middleware sees each logical call once: retries, rate limiting and the
+// HTTP round trip happen inside the innermost handler.
+type Middleware func(next Handler) Handler
+
+// Use appends middleware to the client. Middleware added first is outermost,
+// i.e. it is called first and returns last.
+func (cl *Client) Use(mw ...Middleware) {
+	cl.middleware = append(cl.middleware, mw...)
+}
+
+// handler returns the client's middleware chain wrapped around h.
+func (cl *Client) handler(h Handler) Handler {
+	for i := len(cl.middleware) - 1; i >= 0; i-- {
+		h = cl.middleware[i](h)
+	}
+	return h
+}
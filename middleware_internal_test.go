@@ -0,0 +1,69 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(GetTickerResponse{Pair: r.FormValue("pair")})
+	}))
+	defer srv.Close()
+
+	var trace []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, call *Call, res interface{}) error {
+				trace = append(trace, name+" "+call.Operation)
+				err := next(ctx, call, res)
+				trace = append(trace, name+" "+res.(*GetTickerResponse).Pair)
+				return err
+			}
+		}
+	}
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.Use(record("a"), record("b"))
+
+	_, err := cl.GetTicker(context.Background(), &GetTickerRequest{Pair: "XBTZAR"})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	exp := []string{"a GetTicker", "b GetTicker", "b XBTZAR", "a XBTZAR"}
+	if !reflect.DeepEqual(exp, trace) {
+		t.Errorf("Expected %v, got %v", exp, trace)
+	}
+}
+
+func TestMiddlewareReject(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	cl := NewClient()
+	cl.SetBaseURL("http://invalid.invalid")
+	cl.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *Call, res interface{}) error {
+			req, ok := call.Request.(*PostLimitOrderRequest)
+			if !ok {
+				t.Errorf("Expected *PostLimitOrderRequest, got %T", call.Request)
+			}
+			if req.Pair != "XBTZAR" || call.Method != "POST" || !call.Auth {
+				t.Errorf("Unexpected call %+v", call)
+			}
+			return errRejected
+		}
+	})
+
+	_, err := cl.PostLimitOrder(context.Background(),
+		&PostLimitOrderRequest{Pair: "XBTZAR"})
+	if err != errRejected {
+		t.Errorf("Expected %v, got %v", errRejected, err)
+	}
+}
@@ -0,0 +1,685 @@
+package luno
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Page sizes used by the iterators. They are the maximums accepted by the API.
+const (
+	ordersPageSize       = 100
+	tradesPageSize       = 100
+	transactionsPageSize = 1000
+	withdrawalsPageSize  = 1000
+)
+
+// errNoProgress is returned when a full page contains nothing new, e.g. when
+// more than a page of trades share the same millisecond timestamp.
+var errNoProgress = errors.New("luno: pagination made no progress")
+
+// IterOptions bounds and orders the results of an iterator.
+type IterOptions struct {
+	// Reverse iterates from the most recent result to the oldest. Iterating
+	// against an endpoint's natural order (oldest first for trades and
+	// transactions, newest first for orders) buffers the whole range in
+	// memory before the first result is returned.
+	Reverse bool
+
+	// Since and Until limit results to the time range [Since, Until). Zero
+	// values leave the range open.
+	Since time.Time
+	Until time.Time
+
+	// MinRow and MaxRow limit transactions to the row range [MinRow, MaxRow).
+	// Zero values leave the range open. They are ignored by other iterators.
+	MinRow int64
+	MaxRow int64
+}
+
+func (o IterOptions) inRange(t Time) bool {
+	tt := time.Time(t)
+	return (o.Since.IsZero() || !tt.Before(o.Since)) &&
+		(o.Until.IsZero() || tt.Before(o.Until))
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / 1e6
+}
+
+// TradeIterator iterates over trades. Use Next to advance it:
+//
+//	it := cl.IterTrades(ctx, "XBTZAR", luno.IterOptions{})
+//	for it.Next() {
+//		t := it.Trade()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TradeIterator struct {
+	ctx  context.Context
+	next func(ctx context.Context) ([]Trade, bool, error)
+
+	buf  []Trade
+	cur  Trade
+	done bool
+	err  error
+}
+
+// Next advances the iterator and reports whether there is a trade. It returns
+// false at the end of the range or on error.
+func (it *TradeIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		it.buf, it.done, it.err = it.next(it.ctx)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Trade returns the current trade.
+func (it *TradeIterator) Trade() Trade {
+	return it.cur
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *TradeIterator) Err() error {
+	return it.err
+}
+
+// IterTrades returns an iterator over the public trades of a pair.
+func (cl *Client) IterTrades(ctx context.Context, pair string, opts IterOptions) *TradeIterator {
+	p := &tradePager{
+		opts:        opts,
+		newestFirst: true,
+		since:       toMillis(opts.Since),
+		list: func(ctx context.Context, since int64) ([]Trade, error) {
+			res, err := cl.ListTrades(ctx, &ListTradesRequest{Pair: pair, Since: since})
+			if err != nil {
+				return nil, err
+			}
+			return res.Trades, nil
+		},
+	}
+	return newTradeIterator(ctx, p, opts.Reverse)
+}
+
+// IterUserTrades returns an iterator over the user's trades in a pair.
+func (cl *Client) IterUserTrades(ctx context.Context, pair string, opts IterOptions) *TradeIterator {
+	p := &tradePager{
+		opts:  opts,
+		since: toMillis(opts.Since),
+		list: func(ctx context.Context, since int64) ([]Trade, error) {
+			res, err := cl.ListUserTrades(ctx, &ListUserTradesRequest{
+				Pair:  pair,
+				Since: since,
+				Limit: tradesPageSize,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return res.Trades, nil
+		},
+	}
+	return newTradeIterator(ctx, p, opts.Reverse)
+}
+
+func newTradeIterator(ctx context.Context, p *tradePager, reverse bool) *TradeIterator {
+	it := &TradeIterator{ctx: ctx, next: p.next}
+	if reverse {
+		it.next = func(ctx context.Context) ([]Trade, bool, error) {
+			var all []Trade
+			for {
+				page, done, err := p.next(ctx)
+				if err != nil {
+					return nil, true, err
+				}
+				all = append(all, page...)
+				if done {
+					break
+				}
+			}
+			for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+				all[i], all[j] = all[j], all[i]
+			}
+			return all, true, nil
+		}
+	}
+	return it
+}
+
+// tradePager walks trades forward in time using the since parameter. The
+// trades at the boundary timestamp which were already returned are returned
+// again at the start of the next page, so that many are skipped. Trades are
+// not deduplicated otherwise, since separate trades can be identical.
+type tradePager struct {
+	opts        IterOptions
+	newestFirst bool
+	since       int64
+	// atSince is the number of trades at since already returned.
+	atSince int
+
+	list func(ctx context.Context, since int64) ([]Trade, error)
+}
+
+func (p *tradePager) next(ctx context.Context) ([]Trade, bool, error) {
+	page, err := p.list(ctx, p.since)
+	if err != nil {
+		return nil, true, err
+	}
+	// Reverse pages returned most recent first before sorting so that trades
+	// in the same millisecond keep their order.
+	if p.newestFirst {
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+	}
+	sort.SliceStable(page, func(i, j int) bool {
+		return time.Time(page[i].Timestamp).Before(time.Time(page[j].Timestamp))
+	})
+
+	var out []Trade
+	skip := p.atSince
+	for _, t := range page {
+		ts := toMillis(time.Time(t.Timestamp))
+		if ts < p.since {
+			continue
+		}
+		if ts == p.since && skip > 0 {
+			skip--
+			continue
+		}
+		if !p.opts.Until.IsZero() && !time.Time(t.Timestamp).Before(p.opts.Until) {
+			return out, true, nil
+		}
+		if ts > p.since {
+			p.since = ts
+			p.atSince = 0
+			skip = 0
+		}
+		p.atSince++
+		out = append(out, t)
+	}
+
+	if len(page) < tradesPageSize {
+		return out, true, nil
+	}
+	if len(out) == 0 {
+		return nil, true, errNoProgress
+	}
+	return out, false, nil
+}
+
+// OrderIterator iterates over orders. It is used like TradeIterator.
+type OrderIterator struct {
+	ctx  context.Context
+	next func(ctx context.Context) ([]Order, bool, error)
+
+	buf  []Order
+	cur  Order
+	done bool
+	err  error
+}
+
+// Next advances the iterator and reports whether there is an order. It returns
+// false at the end of the range or on error.
+func (it *OrderIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		it.buf, it.done, it.err = it.next(it.ctx)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Order returns the current order.
+func (it *OrderIterator) Order() Order {
+	return it.cur
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+// IterOrders returns an iterator over the user's orders. The pair and state
+// of req filter the results; its CreatedBefore and Limit fields are ignored.
+// Unlike the other iterators, orders are returned newest first unless
+// opts.Reverse is set.
+func (cl *Client) IterOrders(ctx context.Context, req ListOrdersRequest, opts IterOptions) *OrderIterator {
+	p := &orderPager{
+		opts: opts,
+		list: func(ctx context.Context, createdBefore int64) ([]Order, error) {
+			r := req
+			r.CreatedBefore = createdBefore
+			r.Limit = ordersPageSize
+			res, err := cl.ListOrders(ctx, &r)
+			if err != nil {
+				return nil, err
+			}
+			return res.Orders, nil
+		},
+	}
+	if !opts.Until.IsZero() {
+		p.before = toMillis(opts.Until)
+	}
+
+	it := &OrderIterator{ctx: ctx, next: p.next}
+	if opts.Reverse {
+		it.next = func(ctx context.Context) ([]Order, bool, error) {
+			var all []Order
+			for {
+				page, done, err := p.next(ctx)
+				if err != nil {
+					return nil, true, err
+				}
+				all = append(all, page...)
+				if done {
+					break
+				}
+			}
+			for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+				all[i], all[j] = all[j], all[i]
+			}
+			return all, true, nil
+		}
+	}
+	return it
+}
+
+// orderPager walks orders backward in time using the created_before
+// parameter. Pages overlap by one millisecond so that orders created at the
+// same time as the last order of a page aren't skipped; they are deduplicated
+// by ID.
+type orderPager struct {
+	opts   IterOptions
+	before int64
+	seen   map[string]bool
+
+	list func(ctx context.Context, createdBefore int64) ([]Order, error)
+}
+
+func (p *orderPager) next(ctx context.Context) ([]Order, bool, error) {
+	page, err := p.list(ctx, p.before)
+	if err != nil {
+		return nil, true, err
+	}
+	sort.SliceStable(page, func(i, j int) bool {
+		return time.Time(page[i].CreationTimestamp).After(time.Time(page[j].CreationTimestamp))
+	})
+
+	var out []Order
+	for _, o := range page {
+		ts := toMillis(time.Time(o.CreationTimestamp))
+		if p.seen[o.OrderId] {
+			continue
+		}
+		if !p.opts.Since.IsZero() && time.Time(o.CreationTimestamp).Before(p.opts.Since) {
+			return out, true, nil
+		}
+		if !p.opts.inRange(o.CreationTimestamp) {
+			continue
+		}
+		if p.seen == nil || ts+1 < p.before {
+			p.before = ts + 1
+			p.seen = make(map[string]bool)
+		}
+		p.seen[o.OrderId] = true
+		out = append(out, o)
+	}
+
+	if len(page) < ordersPageSize {
+		return out, true, nil
+	}
+	if len(out) == 0 {
+		return nil, true, errNoProgress
+	}
+	return out, false, nil
+}
+
+// TransactionIterator iterates over account transactions. It is used like
+// TradeIterator.
+type TransactionIterator struct {
+	ctx  context.Context
+	next func(ctx context.Context) ([]Transaction, bool, error)
+
+	buf  []Transaction
+	cur  Transaction
+	done bool
+	err  error
+}
+
+// Next advances the iterator and reports whether there is a transaction. It
+// returns false at the end of the range or on error.
+func (it *TransactionIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		it.buf, it.done, it.err = it.next(it.ctx)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Transaction returns the current transaction.
+func (it *TransactionIterator) Transaction() Transaction {
+	return it.cur
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *TransactionIterator) Err() error {
+	return it.err
+}
+
+// ErrMissingRows is returned by a TransactionIterator if the API skips rows,
+// which would leave a gap in the account history.
+var ErrMissingRows = errors.New("luno: transaction rows missing from response")
+
+// IterTransactions returns an iterator over the transactions of an account,
+// in row order. Rows are checked for completeness: the iterator fails with
+// ErrMissingRows if a row in the requested range is missing.
+func (cl *Client) IterTransactions(ctx context.Context, accountID string, opts IterOptions) *TransactionIterator {
+	p := &transactionPager{
+		opts: opts,
+		list: func(ctx context.Context, min, max int64) ([]Transaction, error) {
+			res, err := cl.ListTransactions(ctx, &ListTransactionsRequest{
+				Id:     accountID,
+				MinRow: min,
+				MaxRow: max,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return res.Transactions, nil
+		},
+	}
+
+	it := &TransactionIterator{ctx: ctx}
+	if opts.Reverse {
+		it.next = p.prev
+	} else {
+		p.row = opts.MinRow
+		if p.row < 1 {
+			p.row = 1
+		}
+		it.next = p.next
+	}
+	return it
+}
+
+// transactionPager walks transactions by row index. Rows are numbered from 1,
+// so pages never overlap.
+type transactionPager struct {
+	opts IterOptions
+	// row is the next row to return.
+	row int64
+
+	list func(ctx context.Context, min, max int64) ([]Transaction, error)
+}
+
+func sortRows(page []Transaction, desc bool) {
+	sort.Slice(page, func(i, j int) bool {
+		if desc {
+			return page[i].RowIndex > page[j].RowIndex
+		}
+		return page[i].RowIndex < page[j].RowIndex
+	})
+}
+
+func (p *transactionPager) next(ctx context.Context) ([]Transaction, bool, error) {
+	max := p.row + transactionsPageSize
+	if p.opts.MaxRow > 0 && max > p.opts.MaxRow {
+		max = p.opts.MaxRow
+	}
+	if max <= p.row {
+		return nil, true, nil
+	}
+
+	page, err := p.list(ctx, p.row, max)
+	if err != nil {
+		return nil, true, err
+	}
+	sortRows(page, false)
+
+	var out []Transaction
+	for _, t := range page {
+		if t.RowIndex < p.row {
+			continue
+		}
+		if t.RowIndex != p.row {
+			return nil, true, ErrMissingRows
+		}
+		p.row++
+		if !p.opts.Until.IsZero() && !time.Time(t.Timestamp).Before(p.opts.Until) {
+			return out, true, nil
+		}
+		if p.opts.inRange(t.Timestamp) {
+			out = append(out, t)
+		}
+	}
+
+	// A short page means we've reached the most recent row.
+	done := p.row < max || p.row == p.opts.MaxRow
+	return out, done, nil
+}
+
+func (p *transactionPager) prev(ctx context.Context) ([]Transaction, bool, error) {
+	min := p.opts.MinRow
+	if min < 1 {
+		min = 1
+	}
+
+	var page []Transaction
+	var err error
+	if p.row == 0 && p.opts.MaxRow <= 0 {
+		// Start from the most recent rows, whose indexes we don't know yet.
+		page, err = p.list(ctx, -transactionsPageSize, 0)
+	} else {
+		if p.row == 0 {
+			p.row = p.opts.MaxRow - 1
+		}
+		if p.row < min {
+			return nil, true, nil
+		}
+		lo := p.row - transactionsPageSize + 1
+		if lo < min {
+			lo = min
+		}
+		page, err = p.list(ctx, lo, p.row+1)
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	sortRows(page, true)
+
+	if p.row == 0 {
+		if len(page) == 0 {
+			return nil, true, nil
+		}
+		p.row = page[0].RowIndex
+	}
+	start := p.row
+
+	var out []Transaction
+	for _, t := range page {
+		if t.RowIndex > p.row {
+			continue
+		}
+		if t.RowIndex != p.row {
+			return nil, true, ErrMissingRows
+		}
+		p.row--
+		if t.RowIndex < min {
+			return out, true, nil
+		}
+		if !p.opts.Since.IsZero() && time.Time(t.Timestamp).Before(p.opts.Since) {
+			return out, true, nil
+		}
+		if p.opts.inRange(t.Timestamp) {
+			out = append(out, t)
+		}
+	}
+
+	if p.row < min {
+		return out, true, nil
+	}
+	if p.row == start {
+		return nil, true, ErrMissingRows
+	}
+	return out, false, nil
+}
+
+// WithdrawalIterator iterates over withdrawals. It is used like
+// TradeIterator.
+type WithdrawalIterator struct {
+	ctx  context.Context
+	next func(ctx context.Context) ([]Withdrawal, bool, error)
+
+	buf  []Withdrawal
+	cur  Withdrawal
+	done bool
+	err  error
+}
+
+// Next advances the iterator and reports whether there is a withdrawal. It
+// returns false at the end of the range or on error.
+func (it *WithdrawalIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		it.buf, it.done, it.err = it.next(it.ctx)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Withdrawal returns the current withdrawal.
+func (it *WithdrawalIterator) Withdrawal() Withdrawal {
+	return it.cur
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *WithdrawalIterator) Err() error {
+	return it.err
+}
+
+// IterWithdrawals returns an iterator over the user's withdrawals. Like
+// orders, withdrawals are returned newest first unless opts.Reverse is set.
+func (cl *Client) IterWithdrawals(ctx context.Context, opts IterOptions) *WithdrawalIterator {
+	p := &withdrawalPager{
+		opts: opts,
+		list: func(ctx context.Context, beforeID int64) ([]Withdrawal, error) {
+			res, err := cl.ListWithdrawals(ctx, &ListWithdrawalsRequest{
+				BeforeId: beforeID,
+				Limit:    withdrawalsPageSize,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return res.Withdrawals, nil
+		},
+	}
+
+	it := &WithdrawalIterator{ctx: ctx, next: p.next}
+	if opts.Reverse {
+		it.next = func(ctx context.Context) ([]Withdrawal, bool, error) {
+			var all []Withdrawal
+			for {
+				page, done, err := p.next(ctx)
+				if err != nil {
+					return nil, true, err
+				}
+				all = append(all, page...)
+				if done {
+					break
+				}
+			}
+			for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+				all[i], all[j] = all[j], all[i]
+			}
+			return all, true, nil
+		}
+	}
+	return it
+}
+
+// withdrawalPager walks withdrawals backward using the before_id parameter.
+// Withdrawal IDs increase in the order they were requested.
+type withdrawalPager struct {
+	opts   IterOptions
+	before int64
+
+	list func(ctx context.Context, beforeID int64) ([]Withdrawal, error)
+}
+
+func (p *withdrawalPager) next(ctx context.Context) ([]Withdrawal, bool, error) {
+	page, err := p.list(ctx, p.before)
+	if err != nil {
+		return nil, true, err
+	}
+
+	ids := make([]int64, len(page))
+	for i, w := range page {
+		if ids[i], err = strconv.ParseInt(w.Id, 10, 64); err != nil {
+			return nil, true, err
+		}
+	}
+	sort.Sort(byIDDesc{page, ids})
+
+	var out []Withdrawal
+	progress := false
+	for i, w := range page {
+		if p.before > 0 && ids[i] >= p.before {
+			continue
+		}
+		p.before, progress = ids[i], true
+		if !p.opts.Since.IsZero() && time.Time(w.CreatedAt).Before(p.opts.Since) {
+			return out, true, nil
+		}
+		if p.opts.inRange(w.CreatedAt) {
+			out = append(out, w)
+		}
+	}
+
+	if len(page) < withdrawalsPageSize {
+		return out, true, nil
+	}
+	if !progress {
+		return nil, true, errNoProgress
+	}
+	return out, false, nil
+}
+
+// byIDDesc sorts withdrawals by their parsed IDs, highest first.
+type byIDDesc struct {
+	ws  []Withdrawal
+	ids []int64
+}
+
+func (s byIDDesc) Len() int           { return len(s.ws) }
+func (s byIDDesc) Less(i, j int) bool { return s.ids[i] > s.ids[j] }
+func (s byIDDesc) Swap(i, j int) {
+	s.ws[i], s.ws[j] = s.ws[j], s.ws[i]
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+}
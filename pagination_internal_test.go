@@ -0,0 +1,411 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func millis(ms int64) Time {
+	return Time(time.Unix(0, ms*1e6))
+}
+
+func formInt(r *http.Request, key string) int64 {
+	i, _ := strconv.ParseInt(r.FormValue(key), 10, 64)
+	return i
+}
+
+// newTradesServer serves trades like the public trades endpoint: the oldest
+// page of trades at or after since, most recent first.
+func newTradesServer(trades []Trade) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := formInt(r, "since")
+		var page []Trade
+		for _, t := range trades {
+			if time.Time(t.Timestamp).UnixNano()/1e6 >= since && len(page) < tradesPageSize {
+				page = append(page, t)
+			}
+		}
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+		json.NewEncoder(w).Encode(ListTradesResponse{Trades: page})
+	}))
+}
+
+// testTrades returns n trades with several trades per millisecond, so that
+// page boundaries fall inside a millisecond.
+func testTrades(n int) []Trade {
+	var trades []Trade
+	for i := 0; i < n; i++ {
+		trades = append(trades, Trade{
+			Timestamp: millis(1000 + int64(i/3)),
+			Price:     decimal.NewFromInt64(int64(i)),
+			Volume:    decimal.NewFromInt64(1),
+		})
+	}
+	return trades
+}
+
+func TestIterTrades(t *testing.T) {
+	trades := testTrades(250)
+	srv := newTradesServer(trades)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	type testCase struct {
+		opts     IterOptions
+		expFirst int64
+		expLast  int64
+		expN     int
+	}
+	since := time.Time(millis(1010))
+	until := time.Time(millis(1050))
+	tests := []testCase{
+		{opts: IterOptions{}, expFirst: 0, expLast: 249, expN: 250},
+		{opts: IterOptions{Reverse: true}, expFirst: 249, expLast: 0, expN: 250},
+		{opts: IterOptions{Since: since, Until: until}, expFirst: 30, expLast: 149, expN: 120},
+		{opts: IterOptions{Since: since, Until: until, Reverse: true}, expFirst: 149, expLast: 30, expN: 120},
+	}
+	for _, test := range tests {
+		it := cl.IterTrades(context.Background(), "XBTZAR", test.opts)
+		var got []int64
+		for it.Next() {
+			got = append(got, int64(it.Trade().Price.Float64()))
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Expected success, got %v", err)
+			continue
+		}
+		if len(got) != test.expN {
+			t.Errorf("Expected %d trades, got %d", test.expN, len(got))
+			continue
+		}
+		if got[0] != test.expFirst || got[len(got)-1] != test.expLast {
+			t.Errorf("Expected trades %d to %d, got %d to %d",
+				test.expFirst, test.expLast, got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestIterTradesNoProgress(t *testing.T) {
+	trades := testTrades(1)
+	for i := 0; i < tradesPageSize; i++ {
+		trades = append(trades, Trade{Timestamp: millis(2000), Price: decimal.NewFromInt64(int64(i))})
+	}
+	srv := newTradesServer(trades)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	it := cl.IterTrades(context.Background(), "XBTZAR", IterOptions{})
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if it.Err() != errNoProgress {
+		t.Errorf("Expected errNoProgress, got %v", it.Err())
+	}
+}
+
+// newUserTradesServer serves trades like the user trades endpoint: the
+// oldest page of trades at or after since, oldest first.
+func newUserTradesServer(trades []Trade) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := formInt(r, "since")
+		var page []Trade
+		for _, t := range trades {
+			if time.Time(t.Timestamp).UnixNano()/1e6 >= since && len(page) < tradesPageSize {
+				page = append(page, t)
+			}
+		}
+		json.NewEncoder(w).Encode(ListUserTradesResponse{Trades: page})
+	}))
+}
+
+func TestIterTradesIdenticalFills(t *testing.T) {
+	// The identical fills at 2000 span the page boundary.
+	trades := testTrades(tradesPageSize - 1)
+	for i := 0; i < 4; i++ {
+		trades = append(trades, Trade{
+			Timestamp: millis(2000),
+			OrderId:   "BX1",
+			Price:     decimal.NewFromInt64(7),
+			Volume:    decimal.NewFromInt64(1),
+			Base:      decimal.NewFromInt64(1),
+			Counter:   decimal.NewFromInt64(7),
+		})
+	}
+	trades = append(trades, Trade{Timestamp: millis(2001), Price: decimal.NewFromInt64(8)})
+
+	for _, user := range []bool{false, true} {
+		var srv *httptest.Server
+		if user {
+			srv = newUserTradesServer(trades)
+		} else {
+			srv = newTradesServer(trades)
+		}
+		cl := NewClient()
+		cl.SetBaseURL(srv.URL)
+
+		for _, reverse := range []bool{false, true} {
+			var it *TradeIterator
+			if user {
+				it = cl.IterUserTrades(context.Background(), "XBTZAR", IterOptions{Reverse: reverse})
+			} else {
+				it = cl.IterTrades(context.Background(), "XBTZAR", IterOptions{Reverse: reverse})
+			}
+			n, fills := 0, 0
+			for it.Next() {
+				n++
+				if time.Time(it.Trade().Timestamp).Equal(time.Time(millis(2000))) {
+					fills++
+				}
+			}
+			if err := it.Err(); err != nil {
+				t.Errorf("Expected success, got %v", err)
+			}
+			if n != len(trades) || fills != 4 {
+				t.Errorf("Expected %d trades with 4 fills (user %t, reverse %t), got %d with %d",
+					len(trades), user, reverse, n, fills)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestIterTradesContext(t *testing.T) {
+	srv := newTradesServer(testTrades(250))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := cl.IterTrades(ctx, "XBTZAR", IterOptions{})
+	if !it.Next() {
+		t.Fatalf("Expected a trade, got %v", it.Err())
+	}
+	cancel()
+	for it.Next() {
+	}
+	if it.Err() != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", it.Err())
+	}
+}
+
+func TestIterOrders(t *testing.T) {
+	// 250 orders, two per millisecond.
+	var orders []Order
+	for i := 0; i < 250; i++ {
+		orders = append(orders, Order{
+			OrderId:           strconv.Itoa(i),
+			CreationTimestamp: millis(1000 + int64(i/2)),
+		})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before := formInt(r, "created_before")
+		var page []Order
+		for i := len(orders) - 1; i >= 0; i-- {
+			ts := time.Time(orders[i].CreationTimestamp).UnixNano() / 1e6
+			if (before == 0 || ts < before) && int64(len(page)) < formInt(r, "limit") {
+				page = append(page, orders[i])
+			}
+		}
+		json.NewEncoder(w).Encode(ListOrdersResponse{Orders: page})
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	type testCase struct {
+		opts     IterOptions
+		expFirst string
+		expLast  string
+		expN     int
+	}
+	tests := []testCase{
+		{opts: IterOptions{}, expFirst: "249", expLast: "0", expN: 250},
+		{opts: IterOptions{Reverse: true}, expFirst: "0", expLast: "249", expN: 250},
+		{
+			opts:     IterOptions{Since: time.Time(millis(1010)), Until: time.Time(millis(1100))},
+			expFirst: "199", expLast: "20", expN: 180,
+		},
+	}
+	for _, test := range tests {
+		it := cl.IterOrders(context.Background(), ListOrdersRequest{}, test.opts)
+		var got []string
+		for it.Next() {
+			got = append(got, it.Order().OrderId)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Expected success, got %v", err)
+			continue
+		}
+		if len(got) != test.expN {
+			t.Errorf("Expected %d orders, got %d", test.expN, len(got))
+			continue
+		}
+		if got[0] != test.expFirst || got[len(got)-1] != test.expLast {
+			t.Errorf("Expected orders %s to %s, got %s to %s",
+				test.expFirst, test.expLast, got[0], got[len(got)-1])
+		}
+	}
+}
+
+// newTransactionsServer serves n transactions, one per second, like the
+// transactions endpoint. Row skip is left out of the response.
+func newTransactionsServer(n int64, skip int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		min, max := formInt(r, "min_row"), formInt(r, "max_row")
+		if min <= 0 {
+			min += n + 1
+		}
+		if max <= 0 {
+			max += n + 1
+		}
+		var txns []Transaction
+		for i := max - 1; i >= min; i-- {
+			if i < 1 || i > n || i == skip {
+				continue
+			}
+			txns = append(txns, Transaction{RowIndex: i, Timestamp: millis(i * 1000)})
+		}
+		json.NewEncoder(w).Encode(ListTransactionsResponse{Transactions: txns})
+	}))
+}
+
+func TestIterTransactions(t *testing.T) {
+	srv := newTransactionsServer(2500, 0)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	type testCase struct {
+		opts     IterOptions
+		expFirst int64
+		expLast  int64
+		expN     int
+	}
+	tests := []testCase{
+		{opts: IterOptions{}, expFirst: 1, expLast: 2500, expN: 2500},
+		{opts: IterOptions{Reverse: true}, expFirst: 2500, expLast: 1, expN: 2500},
+		{opts: IterOptions{MinRow: 10, MaxRow: 1500}, expFirst: 10, expLast: 1499, expN: 1490},
+		{opts: IterOptions{MinRow: 10, MaxRow: 1500, Reverse: true}, expFirst: 1499, expLast: 10, expN: 1490},
+		{
+			opts:     IterOptions{Since: time.Time(millis(5000)), Until: time.Time(millis(2000000))},
+			expFirst: 5, expLast: 1999, expN: 1995,
+		},
+		{
+			opts:     IterOptions{Since: time.Time(millis(5000)), Until: time.Time(millis(2000000)), Reverse: true},
+			expFirst: 1999, expLast: 5, expN: 1995,
+		},
+	}
+	for _, test := range tests {
+		it := cl.IterTransactions(context.Background(), "1", test.opts)
+		var got []int64
+		for it.Next() {
+			got = append(got, it.Transaction().RowIndex)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Expected success, got %v", err)
+			continue
+		}
+		if len(got) != test.expN {
+			t.Errorf("Expected %d transactions, got %d", test.expN, len(got))
+			continue
+		}
+		if got[0] != test.expFirst || got[len(got)-1] != test.expLast {
+			t.Errorf("Expected rows %d to %d, got %d to %d",
+				test.expFirst, test.expLast, got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestIterTransactionsMissingRows(t *testing.T) {
+	srv := newTransactionsServer(20, 7)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	for _, reverse := range []bool{false, true} {
+		it := cl.IterTransactions(context.Background(), "1", IterOptions{Reverse: reverse})
+		for it.Next() {
+		}
+		if it.Err() != ErrMissingRows {
+			t.Errorf("Expected ErrMissingRows, got %v", it.Err())
+		}
+	}
+}
+
+func TestIterWithdrawals(t *testing.T) {
+	// 2500 withdrawals, one per millisecond.
+	var withdrawals []Withdrawal
+	for i := 1; i <= 2500; i++ {
+		withdrawals = append(withdrawals, Withdrawal{
+			Id:        strconv.Itoa(i),
+			CreatedAt: millis(1000 + int64(i)),
+		})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before := formInt(r, "before_id")
+		var page []Withdrawal
+		for i := len(withdrawals) - 1; i >= 0; i-- {
+			id := int64(i + 1)
+			if (before == 0 || id < before) && int64(len(page)) < formInt(r, "limit") {
+				page = append(page, withdrawals[i])
+			}
+		}
+		json.NewEncoder(w).Encode(ListWithdrawalsResponse{Withdrawals: page})
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+
+	type testCase struct {
+		opts     IterOptions
+		expFirst string
+		expLast  string
+		expN     int
+	}
+	tests := []testCase{
+		{opts: IterOptions{}, expFirst: "2500", expLast: "1", expN: 2500},
+		{opts: IterOptions{Reverse: true}, expFirst: "1", expLast: "2500", expN: 2500},
+		{
+			opts:     IterOptions{Since: time.Time(millis(1010)), Until: time.Time(millis(3100))},
+			expFirst: "2099", expLast: "10", expN: 2090,
+		},
+	}
+	for _, test := range tests {
+		it := cl.IterWithdrawals(context.Background(), test.opts)
+		var got []string
+		for it.Next() {
+			got = append(got, it.Withdrawal().Id)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Expected success, got %v", err)
+			continue
+		}
+		if len(got) != test.expN {
+			t.Errorf("Expected %d withdrawals, got %d", test.expN, len(got))
+			continue
+		}
+		if got[0] != test.expFirst || got[len(got)-1] != test.expLast {
+			t.Errorf("Expected withdrawals %s to %s, got %s to %s",
+				test.expFirst, test.expLast, got[0], got[len(got)-1])
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package luno
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket budget. Calls are allowed at Rate per second on
+// average, with bursts of up to Burst calls. A zero Limit allows all calls.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits configures the client-side rate limiter. Every call is charged
+// against the Global bucket and also against the Public bucket (for calls that
+// don't require authentication, e.g. GetTicker or GetOrderBook) or the Private
+// bucket (for authenticated calls, e.g. PostLimitOrder or ListOrders).
+type RateLimits struct {
+	Global  Limit
+	Public  Limit
+	Private Limit
+}
+
+// DefaultRateLimits matches the budgets documented by Luno: one public call
+// per second and five authenticated calls per second, each with short bursts.
+var DefaultRateLimits = RateLimits{
+	Public:  Limit{Rate: 1, Burst: 5},
+	Private: Limit{Rate: 5, Burst: 5},
+}
+
+// SetRateLimits enables client-side rate limiting. Calls block until the
+// relevant buckets have capacity, or until the call's context is done. The
+// limiter is safe to share between goroutines using the same client.
+func (cl *Client) SetRateLimits(limits RateLimits) {
+	cl.globalLimiter = newRateLimiter(limits.Global)
+	cl.publicLimiter = newRateLimiter(limits.Public)
+	cl.privateLimiter = newRateLimiter(limits.Private)
+}
+
+// waitRateLimit blocks until a call of the given kind is allowed. If the call
+// is not allowed, the global token it took is returned.
+func (cl *Client) waitRateLimit(ctx context.Context, auth bool) error {
+	if err := cl.globalLimiter.Wait(ctx); err != nil {
+		return err
+	}
+	rl := cl.publicLimiter
+	if auth {
+		rl = cl.privateLimiter
+	}
+	if err := rl.Wait(ctx); err != nil {
+		cl.globalLimiter.cancel()
+		return err
+	}
+	return nil
+}
+
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+// newRateLimiter returns a limiter for l, or nil if l doesn't limit anything.
+// A nil *rateLimiter allows all calls.
+func newRateLimiter(l Limit) *rateLimiter {
+	if l.Rate <= 0 {
+		return nil
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   l.Rate,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller has to
+// wait before the token becomes valid.
+func (rl *rateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if !rl.last.IsZero() {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+	}
+	rl.last = now
+
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+}
+
+// cancel returns a token taken by reserve that won't be used.
+func (rl *rateLimiter) cancel() {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.tokens++
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+}
+
+// Wait blocks until a call is allowed or ctx is done.
+func (rl *rateLimiter) Wait(ctx context.Context) error {
+	if rl == nil {
+		return nil
+	}
+
+	wait := rl.reserve()
+	if wait == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && rl.now().Add(wait).After(deadline) {
+		rl.cancel()
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		rl.cancel()
+		return ctx.Err()
+	}
+}
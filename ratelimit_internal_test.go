@@ -0,0 +1,124 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(Limit{Rate: 2, Burst: 2})
+	rl.now = func() time.Time { return now }
+
+	// The burst is available immediately.
+	for i := 0; i < 2; i++ {
+		if wait := rl.reserve(); wait != 0 {
+			t.Errorf("Expected no wait for call %d, got %s", i, wait)
+		}
+	}
+
+	// Then calls are spaced out at the configured rate.
+	if wait := rl.reserve(); wait != 500*time.Millisecond {
+		t.Errorf("Expected 500ms wait, got %s", wait)
+	}
+	if wait := rl.reserve(); wait != time.Second {
+		t.Errorf("Expected 1s wait, got %s", wait)
+	}
+
+	// Tokens refill over time, but never beyond the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if wait := rl.reserve(); wait != 0 {
+			t.Errorf("Expected no wait for call %d, got %s", i, wait)
+		}
+	}
+	if wait := rl.reserve(); wait == 0 {
+		t.Errorf("Expected wait after burst")
+	}
+}
+
+func TestRateLimiterNil(t *testing.T) {
+	rl := newRateLimiter(Limit{})
+	if rl != nil {
+		t.Fatalf("Expected nil limiter for zero limit")
+	}
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+}
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	rl := newRateLimiter(Limit{Rate: 0.001, Burst: 1})
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := rl.Wait(ctx); err != context.Canceled {
+		t.Errorf("Expected canceled, got %v", err)
+	}
+}
+
+func TestDoRateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRateLimits(RateLimits{
+		Public: Limit{Rate: 0.001, Burst: 1},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var res interface{}
+	if err := cl.do(ctx, "", "GET", "/", nil, &res, false); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if err := cl.do(ctx, "", "GET", "/", nil, &res, false); err != context.DeadlineExceeded {
+		t.Errorf("Expected public call to be rate limited, got %v", err)
+	}
+	if err := cl.do(ctx, "", "GET", "/", nil, &res, true); err != nil {
+		t.Errorf("Expected private call not to be rate limited, got %v", err)
+	}
+}
+
+func TestWaitRateLimitReturnsGlobalToken(t *testing.T) {
+	cl := NewClient()
+	cl.SetRateLimits(RateLimits{
+		Global: Limit{Rate: 0.001, Burst: 2},
+		Public: Limit{Rate: 0.001, Burst: 1},
+	})
+	if err := cl.waitRateLimit(context.Background(), false); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	// The public bucket is empty, so cancelled calls must not use up the
+	// global bucket.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := cl.waitRateLimit(ctx, false); err != context.Canceled {
+			t.Errorf("Expected canceled, got %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cl.waitRateLimit(ctx, true); err != nil {
+		t.Errorf("Expected private call to use the remaining global token, got %v", err)
+	}
+}
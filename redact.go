@@ -0,0 +1,91 @@
+package luno
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Redacted replaces sensitive values in log messages.
+const Redacted = "[REDACTED]"
+
+// sensitiveFields are request parameters and response keys whose values must
+// not be logged.
+var sensitiveFields = map[string]bool{
+	"address":           true,
+	"amount":            true,
+	"api_key_id":        true,
+	"api_key_secret":    true,
+	"available":         true,
+	"balance":           true,
+	"base_amount":       true,
+	"beneficiary_id":    true,
+	"description":       true,
+	"message":           true,
+	"reference":         true,
+	"reserved":          true,
+	"total_received":    true,
+	"total_unconfirmed": true,
+	"unconfirmed":       true,
+}
+
+// RedactString returns a redacted form of a credential, keeping only enough
+// of it to tell different credentials apart.
+func RedactString(s string) string {
+	if len(s) <= 8 {
+		return Redacted
+	}
+	return s[:4] + "..." + Redacted
+}
+
+// redactRequest returns the parameters of a request struct with sensitive
+// values redacted.
+func redactRequest(req interface{}) map[string]string {
+	if req == nil {
+		return nil
+	}
+	values, err := makeURLValues(req)
+	if err != nil {
+		return nil
+	}
+	r := make(map[string]string)
+	for k := range values {
+		if sensitiveFields[k] {
+			r[k] = Redacted
+		} else {
+			r[k] = values.Get(k)
+		}
+	}
+	return r
+}
+
+// redactJSON returns the JSON document b with the values of sensitive keys
+// redacted at any depth. Documents which can't be parsed are not logged.
+func redactJSON(b []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	r, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	return string(r)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if sensitiveFields[k] {
+				v[k] = Redacted
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+	}
+	return v
+}
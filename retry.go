@@ -0,0 +1,119 @@
+package luno
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy configures automatic retries of failed calls.
+//
+// Calls that fail with a network error, a rate limit (HTTP 429) or a server
+// error (HTTP 5xx) are retried with exponential backoff and full jitter. If the
+// server sends a Retry-After hint, the client waits at least that long.
+//
+// Read-only (GET) calls are always eligible for retry. Calls that change state,
+// e.g. PostLimitOrder or Send, may have taken effect even if they failed, so
+// they are only retried if the context carries an idempotency key set with
+// WithIdempotencyKey.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. A
+	// value of 1 or less disables retries.
+	MaxAttempts int
+
+	// MinBackoff is the upper bound of the wait before the first retry. It
+	// doubles with every attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries up to three times over roughly ten seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// SetRetryPolicy enables automatic retries. Retries are disabled by default.
+func (cl *Client) SetRetryPolicy(p RetryPolicy) {
+	cl.retryPolicy = p
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context which marks calls made with it as safe
+// to retry. The key is sent in the Idempotency-Key header and should be unique
+// for each logical operation, e.g. each order the caller intends to place.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func getIdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// IsRetryable reports whether err is a transient error after which the call
+// may be retried, i.e. a rate limit, a server error or a network error.
+// Other errors, e.g. failing to build the request or decode the response,
+// would recur.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.HTTPStatus == http.StatusTooManyRequests ||
+			e.HTTPStatus >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
+
+// canRetry reports whether a call with the given method may be retried at all.
+func canRetry(ctx context.Context, method string) bool {
+	return method == http.MethodGet || getIdempotencyKey(ctx) != ""
+}
+
+// backoff returns the wait before the given retry attempt (starting at 1).
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	max := p.MinBackoff
+	for i := 1; i < attempt && max < p.MaxBackoff; i++ {
+		max *= 2
+	}
+	if p.MaxBackoff > 0 && max > p.MaxBackoff {
+		max = p.MaxBackoff
+	}
+
+	var wait time.Duration
+	if max > 0 {
+		wait = time.Duration(rand.Int63n(int64(max)))
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.RetryAfter > wait {
+		wait = e.RetryAfter
+	}
+	return wait
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
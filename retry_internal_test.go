@@ -0,0 +1,204 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  2 * time.Millisecond,
+}
+
+// newFlakyServer returns a server which fails with status until it has been
+// called failures times.
+func newFlakyServer(status int, failures int32, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte("{}"))
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Key string `json:"key"`
+		}{Key: r.Header.Get("Idempotency-Key")})
+	}))
+}
+
+func TestDoRetryGet(t *testing.T) {
+	var calls int32
+	srv := newFlakyServer(http.StatusServiceUnavailable, 2, &calls)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
+	if err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected 3 calls, got %d", n)
+	}
+}
+
+func TestDoRetryGivesUp(t *testing.T) {
+	var calls int32
+	srv := newFlakyServer(http.StatusTooManyRequests, 10, &calls)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Expected ErrTooManyRequests, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected 3 calls, got %d", n)
+	}
+}
+
+func TestDoNoRetryClientError(t *testing.T) {
+	var calls int32
+	srv := newFlakyServer(http.StatusBadRequest, 1, &calls)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestDoNoRetryPostWithoutIdempotencyKey(t *testing.T) {
+	var calls int32
+	srv := newFlakyServer(http.StatusServiceUnavailable, 1, &calls)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "POST", "/", nil, &res, true)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestDoRetryPostWithIdempotencyKey(t *testing.T) {
+	var calls int32
+	srv := newFlakyServer(http.StatusServiceUnavailable, 1, &calls)
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+
+	var res struct {
+		Key string `json:"key"`
+	}
+	err := cl.do(ctx, "", "POST", "/", nil, &res, true)
+	if err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+	if res.Key != "order-1" {
+		t.Errorf("Expected idempotency key %q, got %q", "order-1", res.Key)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	type testCase struct {
+		err error
+		exp bool
+	}
+
+	testCases := []testCase{
+		{err: nil, exp: false},
+		{err: &Error{HTTPStatus: http.StatusServiceUnavailable}, exp: true},
+		{err: &Error{HTTPStatus: http.StatusTooManyRequests}, exp: true},
+		{err: &Error{HTTPStatus: http.StatusBadRequest}, exp: false},
+		{err: &url.Error{Op: "Get", URL: "/", Err: errors.New("connection reset")}, exp: true},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, exp: true},
+		{err: &url.Error{Op: "Get", URL: "/", Err: context.Canceled}, exp: false},
+		{err: context.DeadlineExceeded, exp: false},
+		{err: &json.SyntaxError{}, exp: false},
+		{err: errors.New("building request"), exp: false},
+	}
+
+	for _, tc := range testCases {
+		if act := IsRetryable(tc.err); act != tc.exp {
+			t.Errorf("Expected %v for %v, got %v", tc.exp, tc.err, act)
+		}
+	}
+}
+
+func TestDoNoRetryDecodeError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("not json"))
+	}))
+	defer srv.Close()
+
+	cl := NewClient()
+	cl.SetBaseURL(srv.URL)
+	cl.SetRetryPolicy(testRetryPolicy)
+
+	var res interface{}
+	err := cl.do(context.Background(), "", "GET", "/", nil, &res, false)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 40 * time.Millisecond,
+	}
+
+	for attempt := 1; attempt < 10; attempt++ {
+		wait := p.backoff(attempt, nil)
+		if wait < 0 || wait >= p.MaxBackoff {
+			t.Errorf("Expected backoff in [0, %s), got %s", p.MaxBackoff, wait)
+		}
+	}
+
+	err := &Error{HTTPStatus: http.StatusTooManyRequests, RetryAfter: time.Second}
+	if wait := p.backoff(1, err); wait != time.Second {
+		t.Errorf("Expected Retry-After to be honoured, got %s", wait)
+	}
+}
@@ -0,0 +1,191 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// Discrepancy is a price level whose volume differs between the streamed
+// order book and the REST order book. A volume is zero if the level is
+// missing from that book.
+type Discrepancy struct {
+	Side   luno.OrderType
+	Price  decimal.Decimal
+	Local  decimal.Decimal
+	Remote decimal.Decimal
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s %s: local %s, remote %s", d.Side, d.Price, d.Local, d.Remote)
+}
+
+// AuditReport is the result of comparing the streamed order book with the
+// REST order book.
+type AuditReport struct {
+	Pair string
+	Time time.Time
+	// Sequence is the sequence number of the streamed order book.
+	Sequence int64
+	// Levels is the number of price levels compared. The REST order book
+	// only has the best levels, so streamed levels beyond its worst price on
+	// each side are not compared.
+	Levels        int
+	Discrepancies []Discrepancy
+	// Divergence is the fraction of the compared levels with discrepancies.
+	Divergence float64
+	// Reset is set if the divergence exceeded the threshold set by
+	// WithAuditReset.
+	Reset bool
+	// epoch is the connection's epoch when the book was read.
+	epoch int64
+}
+
+// errNotLive is returned by audit when the order book is not live.
+var errNotLive = errors.New("streaming: order book is not live")
+
+// resetRequest asks the connection to resync the book it had in epoch.
+type resetRequest struct {
+	epoch int64
+	err   error
+}
+
+// epoch returns the number of times the connection has replaced its order
+// book with a snapshot by connecting or resyncing.
+func (c *Connection) epoch() int64 {
+	s := c.Stats()
+	return s.Connections + s.Resyncs
+}
+
+// AuditCallback is called with the reports of an order book audit.
+type AuditCallback func(AuditReport)
+
+// auditForever audits the order book every auditInterval until the
+// connection is closed.
+func (c *Connection) auditForever() {
+	t := time.NewTicker(c.auditInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-c.ctx.Done():
+			return
+		}
+		if !c.MessageProcessor.isLive() {
+			continue
+		}
+
+		r, err := c.audit(c.ctx)
+		if err == errNotLive {
+			continue
+		} else if err != nil {
+			if c.ctx.Err() == nil {
+				c.log(luno.LevelWarn, "Audit failed",
+					luno.Field{Key: "error", Value: err})
+			}
+			continue
+		}
+
+		if len(r.Discrepancies) > 0 {
+			c.log(luno.LevelWarn, "Order book differs from REST order book",
+				luno.Field{Key: "sequence", Value: r.Sequence},
+				luno.Field{Key: "levels", Value: r.Levels},
+				luno.Field{Key: "discrepancies", Value: len(r.Discrepancies)})
+		}
+		if c.auditThreshold >= 0 && r.Divergence > c.auditThreshold {
+			r.Reset = true
+			err := fmt.Errorf("streaming: order book diverged from REST "+
+				"order book at sequence %d: %d of %d levels differ",
+				r.Sequence, len(r.Discrepancies), r.Levels)
+			select {
+			case c.resets <- resetRequest{epoch: r.epoch, err: err}:
+			default:
+			}
+		}
+		if c.auditCallback != nil {
+			c.auditCallback(r)
+		}
+	}
+}
+
+// audit fetches the REST order book and compares it with the streamed one.
+func (c *Connection) audit(ctx context.Context) (AuditReport, error) {
+	res, err := c.auditClient.GetOrderBook(ctx,
+		&luno.GetOrderBookRequest{Pair: c.pair})
+	if err != nil {
+		return AuditReport{}, err
+	}
+	epoch := c.epoch()
+	seq, bids, asks := c.MessageProcessor.orderbook.depth(-1)
+	if !c.MessageProcessor.isLive() {
+		// The book may be empty, seeded from a checkpoint or being resynced.
+		return AuditReport{}, errNotLive
+	}
+
+	r := AuditReport{Pair: c.pair, Time: time.Now(), Sequence: seq, epoch: epoch}
+	r.compare(luno.OrderTypeBid, bids, aggregate(res.Bids), true)
+	r.compare(luno.OrderTypeAsk, asks, aggregate(res.Asks), false)
+	if r.Levels > 0 {
+		r.Divergence = float64(len(r.Discrepancies)) / float64(r.Levels)
+	}
+	return r, nil
+}
+
+// compare adds the levels of one side to the report. Both sides are in price
+// order from the best, descending if desc is set.
+func (r *AuditReport) compare(side luno.OrderType, local,
+	remote []luno.OrderBookEntry, desc bool) {
+
+	// better reports whether a is a better price than b.
+	better := func(a, b decimal.Decimal) bool {
+		if desc {
+			return a.Cmp(b) > 0
+		}
+		return a.Cmp(b) < 0
+	}
+	if len(remote) > 0 {
+		worst := remote[len(remote)-1].Price
+		n := 0
+		for n < len(local) && !better(worst, local[n].Price) {
+			n++
+		}
+		local = local[:n]
+	}
+
+	zero := decimal.Zero()
+	for len(local) > 0 || len(remote) > 0 {
+		d := Discrepancy{Side: side, Local: zero, Remote: zero}
+		switch {
+		case len(remote) == 0 || len(local) > 0 && better(local[0].Price, remote[0].Price):
+			d.Price, d.Local = local[0].Price, local[0].Volume
+			local = local[1:]
+		case len(local) == 0 || better(remote[0].Price, local[0].Price):
+			d.Price, d.Remote = remote[0].Price, remote[0].Volume
+			remote = remote[1:]
+		default:
+			d.Price, d.Local, d.Remote = local[0].Price, local[0].Volume, remote[0].Volume
+			local, remote = local[1:], remote[1:]
+		}
+		r.Levels++
+		if d.Local.Cmp(d.Remote) != 0 {
+			r.Discrepancies = append(r.Discrepancies, d)
+		}
+	}
+}
+
+// aggregate sums the volumes of consecutive entries at the same price.
+func aggregate(entries []luno.OrderBookEntry) []luno.OrderBookEntry {
+	var levels []luno.OrderBookEntry
+	for _, e := range entries {
+		if n := len(levels); n > 0 && levels[n-1].Price.Cmp(e.Price) == 0 {
+			levels[n-1].Volume = levels[n-1].Volume.Add(e.Volume)
+			continue
+		}
+		levels = append(levels, e)
+	}
+	return levels
+}
@@ -0,0 +1,141 @@
+package streaming
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/lunomock"
+)
+
+func restOrderBook(t *testing.T, bids, asks []*order) *lunomock.Client {
+	entries := func(ol []*order) []luno.OrderBookEntry {
+		var r []luno.OrderBookEntry
+		for _, o := range ol {
+			r = append(r, luno.OrderBookEntry{Price: o.Price, Volume: o.Volume})
+		}
+		return r
+	}
+	var client lunomock.Client
+	client.GetOrderBookFunc = func(ctx context.Context,
+		req *luno.GetOrderBookRequest) (*luno.GetOrderBookResponse, error) {
+
+		if req.Pair != "XBTZAR" {
+			t.Errorf("Expected pair XBTZAR, got %s", req.Pair)
+		}
+		return &luno.GetOrderBookResponse{Bids: entries(bids), Asks: entries(asks)}, nil
+	}
+	return &client
+}
+
+func TestAudit(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{
+		testOrder(t, "b1", "100", "1"),
+		testOrder(t, "b2", "100", "2"),
+		testOrder(t, "b3", "99", "1"),
+	}, []*order{
+		testOrder(t, "a1", "101", "1"),
+		testOrder(t, "a2", "103", "1"),
+	})
+
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The REST book does not conflate orders and only has the best ask.
+	c.auditClient = restOrderBook(t, []*order{
+		testOrder(t, "", "100", "2"),
+		testOrder(t, "", "100", "1"),
+		testOrder(t, "", "98", "1"),
+	}, []*order{
+		testOrder(t, "", "101", "2"),
+	})
+	r, err := c.audit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Pair != "XBTZAR" || r.Sequence != 1 || r.Levels != 4 || r.Divergence != 0.75 {
+		t.Errorf("Unexpected report %+v", r)
+	}
+	var act []string
+	for _, d := range r.Discrepancies {
+		act = append(act, d.String())
+	}
+	exp := []string{
+		"BID 99: local 1, remote 0",
+		"BID 98: local 0, remote 1",
+		"ASK 101: local 1, remote 2",
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected discrepancies %v, got %v", exp, act)
+	}
+}
+
+func TestAuditorReset(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{testOrder(t, "b1", "100", "1")}, nil)
+
+	var mu sync.Mutex
+	var reports []AuditReport
+	var r eventRecorder
+	client := restOrderBook(t, []*order{testOrder(t, "", "100", "2")}, nil)
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithEventCallback(r.record), WithAuditReset(0.5),
+		WithAuditor(client, 10*time.Millisecond, func(r AuditReport) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, r)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The streamed book drifted, so the auditor resyncs it.
+	srv.setSnapshot("XBTZAR", 5, []*order{testOrder(t, "b1", "100", "2")}, nil)
+	waitFor(t, "matching audit", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		n := len(reports)
+		return n > 0 && reports[n-1].Sequence == 5 && len(reports[n-1].Discrepancies) == 0
+	})
+
+	mu.Lock()
+	if !reports[0].Reset || reports[0].Divergence != 1 {
+		t.Errorf("Expected first audit to reset, got %+v", reports[0])
+	}
+	mu.Unlock()
+	if stats := c.Stats(); stats.Resyncs != 1 || stats.Connections != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if e := r.get()[3]; e.Type != EventResyncing || e.Err == nil {
+		t.Errorf("Expected resync caused by audit, got %+v", e)
+	}
+}
+
+func TestAuditNotLive(t *testing.T) {
+	c := newConnection(context.Background(), "XBTZAR",
+		[]DialOption{WithLogger(discardLogger), WithCheckpoint(testCheckpoint(t))})
+	defer c.Close()
+	c.auditClient = restOrderBook(t, nil, nil)
+
+	// A book seeded from a checkpoint has a sequence but is not live.
+	if _, err := c.audit(context.Background()); err != errNotLive {
+		t.Errorf("Expected errNotLive for seeded book, got %v", err)
+	}
+}
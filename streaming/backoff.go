@@ -0,0 +1,64 @@
+package streaming
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff decides how long a connection waits before reconnecting.
+// Implementations used with a Manager are shared by its connections, so they
+// must be safe for concurrent use.
+type Backoff interface {
+	// Next returns how long to wait before reconnecting after a connection
+	// attempt which started at start.
+	Next(start time.Time) time.Duration
+}
+
+// BackoffFunc adapts a function to the Backoff interface.
+type BackoffFunc func(start time.Time) time.Duration
+
+// Next returns f(start).
+func (f BackoffFunc) Next(start time.Time) time.Duration {
+	return f(start)
+}
+
+// ConstantBackoff returns a Backoff which always waits d.
+func ConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(time.Time) time.Duration { return d })
+}
+
+// NewExponentialBackoff returns the default Backoff. The wait starts at 10s
+// and doubles with every attempt up to 160s, plus up to 100% random jitter.
+// After a connection which lasted more than an hour, the wait is 5s and the
+// attempts start again.
+func NewExponentialBackoff() Backoff {
+	return new(backoff)
+}
+
+// backoff is the default exponential Backoff. It may be shared by several
+// connections, e.g. those of a Manager, so that they back off together when
+// the streaming service is unavailable.
+type backoff struct {
+	mu       sync.Mutex
+	attempts int
+}
+
+func (b *backoff) Next(start time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempts++
+	if time.Now().Sub(start) > time.Hour {
+		b.attempts = 0
+	}
+	if b.attempts > 5 {
+		b.attempts = 5
+	}
+	wait := 5
+	for i := 0; i < b.attempts; i++ {
+		wait = 2 * wait
+	}
+	wait = wait + rand.Intn(wait)
+	return time.Duration(wait) * time.Second
+}
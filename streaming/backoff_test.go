@@ -0,0 +1,25 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := NewExponentialBackoff()
+	check := func(when string, d, min time.Duration) {
+		if d < min || d >= 2*min {
+			t.Errorf("Expected wait %s in [%s, %s), got %s", when, min, 2*min, d)
+		}
+	}
+
+	now := time.Now()
+	check("after the first attempt", b.Next(now), 10*time.Second)
+	check("after the second attempt", b.Next(now), 20*time.Second)
+	for i := 0; i < 5; i++ {
+		b.Next(now)
+	}
+	check("after many attempts", b.Next(now), 160*time.Second)
+	check("after a long connection", b.Next(now.Add(-2*time.Hour)), 5*time.Second)
+	check("after the next attempt", b.Next(now), 10*time.Second)
+}
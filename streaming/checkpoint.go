@@ -0,0 +1,180 @@
+package streaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// ErrStaleSnapshot is the reason a connection seeded with WithCheckpoint
+// reconnects when the first snapshot it receives is older than the
+// checkpoint.
+var ErrStaleSnapshot = errors.New("streaming: snapshot is older than the checkpoint")
+
+// Checkpoint is a saved order book, from which a Connection or Replay can be
+// seeded with WithCheckpoint, or an OrderBook created with NewOrderBook.
+type Checkpoint struct {
+	Pair     string            `json:"pair"`
+	Sequence int64             `json:"sequence,string"`
+	Time     time.Time         `json:"time"`
+	Bids     []CheckpointOrder `json:"bids"`
+	Asks     []CheckpointOrder `json:"asks"`
+}
+
+// CheckpointOrder is an order in a Checkpoint.
+type CheckpointOrder struct {
+	ID     string          `json:"id"`
+	Price  decimal.Decimal `json:"price,string"`
+	Volume decimal.Decimal `json:"volume,string"`
+}
+
+// Checkpoint returns the connection's order book. Orders are in price order,
+// best first, and in the order they were added at each price. The sequence is
+// 0 if the book is empty.
+func (c *Connection) Checkpoint() Checkpoint {
+	return c.MessageProcessor.orderbook.checkpoint(c.pair)
+}
+
+// checkpoint returns the book as a checkpoint for pair.
+func (ob *orderbookState) checkpoint(pair string) Checkpoint {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return Checkpoint{
+		Pair:     pair,
+		Sequence: ob.seq,
+		Time:     time.Now(),
+		Bids:     ob.bids.checkpoint(),
+		Asks:     ob.asks.checkpoint(),
+	}
+}
+
+// checkpoint returns the side's orders, best first.
+func (s *bookSide) checkpoint() []CheckpointOrder {
+	if s == nil {
+		return nil
+	}
+	ol := make([]CheckpointOrder, 0, s.orders)
+	for l := s.best(); l != nil; l = l.next[0] {
+		for o := l.first; o != nil; o = o.next {
+			ol = append(ol, CheckpointOrder{ID: o.ID, Price: o.Price, Volume: o.Volume})
+		}
+	}
+	return ol
+}
+
+// WriteCheckpoint writes cp to w as JSON.
+func WriteCheckpoint(w io.Writer, cp Checkpoint) error {
+	return json.NewEncoder(w).Encode(cp)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint and checks
+// that it is a valid order book.
+func ReadCheckpoint(r io.Reader) (Checkpoint, error) {
+	var cp Checkpoint
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return Checkpoint{}, err
+	}
+	if err := cp.validate(); err != nil {
+		return Checkpoint{}, err
+	}
+	return cp, nil
+}
+
+// validate checks that cp has a sequence and that its orders are unique and
+// have positive prices and volumes.
+func (cp Checkpoint) validate() error {
+	if cp.Sequence <= 0 {
+		return fmt.Errorf("streaming: checkpoint has invalid sequence %d", cp.Sequence)
+	}
+	ids := make(map[string]bool)
+	for _, ol := range [][]CheckpointOrder{cp.Bids, cp.Asks} {
+		for _, o := range ol {
+			if ids[o.ID] {
+				return fmt.Errorf("streaming: checkpoint has duplicate order %s", o.ID)
+			}
+			ids[o.ID] = true
+			if o.Price.Sign() <= 0 || o.Volume.Sign() <= 0 {
+				return fmt.Errorf("streaming: checkpoint order %s has price %s and volume %s",
+					o.ID, o.Price, o.Volume)
+			}
+		}
+	}
+	return nil
+}
+
+// checkpointOrders converts checkpoint orders to the processor's orders.
+func checkpointOrders(ol []CheckpointOrder) []*order {
+	r := make([]*order, 0, len(ol))
+	for _, o := range ol {
+		r = append(r, &order{ID: o.ID, Price: o.Price, Volume: o.Volume})
+	}
+	return r
+}
+
+// seed sets the order book from the pending checkpoint, if any.
+func (c *Connection) seed() {
+	cp := c.checkpoint
+	if cp == nil {
+		return
+	}
+	c.MessageProcessor.orderbook.Set(cp.Sequence,
+		checkpointOrders(cp.Bids), checkpointOrders(cp.Asks))
+}
+
+// checkCheckpoint checks a snapshot at seq against the pending checkpoint.
+// The checkpoint is dropped either way, so that a server which is behind it
+// does not keep the connection from becoming ready.
+func (c *Connection) checkCheckpoint(seq int64) error {
+	cp := c.checkpoint
+	if cp == nil {
+		return nil
+	}
+	c.checkpoint = nil
+	if seq < cp.Sequence {
+		return fmt.Errorf("%w: sequence %d, checkpoint %d",
+			ErrStaleSnapshot, seq, cp.Sequence)
+	}
+	return nil
+}
+
+// OrderBook is an order book restored from a Checkpoint outside a
+// Connection, to which updates received elsewhere can be applied. It is safe
+// for concurrent use.
+type OrderBook struct {
+	pair string
+	m    messageProcessor
+}
+
+// NewOrderBook returns an OrderBook seeded from cp, which must be valid.
+func NewOrderBook(cp Checkpoint) (*OrderBook, error) {
+	if err := cp.validate(); err != nil {
+		return nil, err
+	}
+	b := &OrderBook{pair: cp.Pair}
+	b.m.orderbook.Set(cp.Sequence, checkpointOrders(cp.Bids), checkpointOrders(cp.Asks))
+	return b, nil
+}
+
+// Apply applies u to the order book. Updates at or before the book's sequence
+// are ignored, and an error is returned if u does not follow it.
+func (b *OrderBook) Apply(u UpdateMessage) error {
+	_, _, err := b.m.applyUpdate(u)
+	return err
+}
+
+// Depth returns the book's sequence and up to n price levels on each side,
+// like Connection.Depth.
+func (b *OrderBook) Depth(n int) (int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
+	return b.m.orderbook.depth(n)
+}
+
+// Checkpoint returns the order book, like Connection.Checkpoint.
+func (b *OrderBook) Checkpoint() Checkpoint {
+	return b.m.orderbook.checkpoint(b.pair)
+}
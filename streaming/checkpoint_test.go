@@ -0,0 +1,247 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1,
+		[]*order{testOrder(t, "b1", "100", "1"), testOrder(t, "b2", "100", "2")},
+		[]*order{testOrder(t, "a1", "101", "2")})
+
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := c.Checkpoint()
+	var buf bytes.Buffer
+	if err := WriteCheckpoint(&buf, cp); err != nil {
+		t.Fatal(err)
+	}
+	act, err := ReadCheckpoint(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if act.Pair != "XBTZAR" || act.Sequence != 1 || !act.Time.Equal(cp.Time) {
+		t.Errorf("Expected XBTZAR at 1, got %s at %d", act.Pair, act.Sequence)
+	}
+	var ids []string
+	for _, o := range append(act.Bids, act.Asks...) {
+		ids = append(ids, o.ID+"@"+o.Price.String()+"x"+o.Volume.String())
+	}
+	if exp := []string{"b1@100x1", "b2@100x2", "a1@101x2"}; !reflect.DeepEqual(exp, ids) {
+		t.Errorf("Expected orders %v, got %v", exp, ids)
+	}
+}
+
+func TestReadCheckpointInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{"pair":"XBTZAR","sequence":"0","bids":[],"asks":[]}`,
+		`{"pair":"XBTZAR","sequence":"1","bids":[{"id":"a","price":"1","volume":"1"}],"asks":[{"id":"a","price":"2","volume":"1"}]}`,
+		`{"pair":"XBTZAR","sequence":"1","bids":[{"id":"a","price":"1","volume":"0"}]}`,
+		`{"pair":"XBTZAR","sequence":"1","bids":[{"id":"a","price":"-1","volume":"1"}]}`,
+		`not json`,
+	} {
+		if _, err := ReadCheckpoint(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected error for %s", s)
+		}
+	}
+}
+
+func testCheckpoint(t *testing.T) Checkpoint {
+	return Checkpoint{
+		Pair:     "XBTZAR",
+		Sequence: 10,
+		Bids:     []CheckpointOrder{{ID: "b1", Price: dec(t, "100"), Volume: dec(t, "1")}},
+		Asks:     []CheckpointOrder{{ID: "a1", Price: dec(t, "101"), Volume: dec(t, "2")}},
+	}
+}
+
+func TestOrderBook(t *testing.T) {
+	if _, err := NewOrderBook(Checkpoint{Pair: "XBTZAR"}); err == nil {
+		t.Errorf("Expected error for invalid checkpoint")
+	}
+
+	b, err := NewOrderBook(testCheckpoint(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []UpdateMessage{
+		{Sequence: 10, DeleteUpdate: &DeleteUpdateMessage{OrderID: "b1"}},
+		{Sequence: 11, CreateUpdate: &CreateUpdateMessage{OrderID: "b2",
+			Type: "BID", Price: dec(t, "99"), Volume: dec(t, "3")}},
+	} {
+		if err := b.Apply(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Apply(UpdateMessage{Sequence: 13}); err == nil {
+		t.Errorf("Expected error for sequence gap")
+	}
+
+	// Update 10 is already in the checkpoint, so b1 remains.
+	seq, bids, asks := b.Depth(-1)
+	if seq != 11 || !reflect.DeepEqual([]string{"101@2.00000000"}, levelStrings(asks)) ||
+		!reflect.DeepEqual([]string{"100@1.00000000", "99@3.00000000"}, levelStrings(bids)) {
+		t.Errorf("Expected updates applied to the checkpoint, got %d %v %v", seq, bids, asks)
+	}
+	if cp := b.Checkpoint(); cp.Pair != "XBTZAR" || cp.Sequence != 11 || len(cp.Bids) != 2 {
+		t.Errorf("Unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestWithCheckpoint(t *testing.T) {
+	host, stop := newBrokenHost()
+	defer stop()
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", host, WithLogger(discardLogger),
+		WithEventCallback(r.record), WithCheckpoint(testCheckpoint(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	check := func(when string) {
+		seq, bids, asks := c.Depth(-1)
+		if seq != 10 || !reflect.DeepEqual([]string{"100@1.00000000"}, levelStrings(bids)) ||
+			!reflect.DeepEqual([]string{"101@2.00000000"}, levelStrings(asks)) {
+			t.Errorf("Expected the checkpoint %s, got %d %v %v", when, seq, bids, asks)
+		}
+	}
+	check("before connecting")
+
+	// The book is seeded again after the failed connection.
+	waitFor(t, "backoff", func() bool { return len(r.get()) >= 3 })
+	check("after a failed connection")
+}
+
+func TestWithCheckpointSnapshot(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 12, nil, []*order{testOrder(t, "a2", "102", "1")})
+
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithCheckpoint(testCheckpoint(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshot replaces the checkpoint.
+	seq, bids, asks := c.Depth(-1)
+	if seq != 12 || len(bids) != 0 || !reflect.DeepEqual([]string{"102@1.00000000"}, levelStrings(asks)) {
+		t.Errorf("Expected the snapshot, got %d %v %v", seq, bids, asks)
+	}
+}
+
+func TestWithCheckpointStaleSnapshot(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 5, nil, []*order{testOrder(t, "a0", "103", "1")})
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithEventCallback(r.record), WithBackoff(ConstantBackoff(time.Millisecond)),
+		WithCheckpoint(testCheckpoint(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every snapshot is older than the checkpoint, so the first is rejected
+	// and the next connection's replaces the checkpoint.
+	if seq, _, asks := c.Depth(-1); seq != 5 ||
+		!reflect.DeepEqual([]string{"103@1.00000000"}, levelStrings(asks)) {
+		t.Errorf("Expected the older snapshot, got %d %v", seq, asks)
+	}
+	var rejected int
+	for _, e := range r.get() {
+		if e.Type == EventDisconnected && errors.Is(e.Err, ErrStaleSnapshot) {
+			rejected++
+		}
+	}
+	if rejected != 1 {
+		t.Errorf("Expected one disconnection with ErrStaleSnapshot, got %v", r.get())
+	}
+}
+
+func TestWithCheckpointOtherPair(t *testing.T) {
+	cp := testCheckpoint(t)
+	cp.Pair = "ETHZAR"
+	r := NewReplay(strings.NewReader(""), "XBTZAR", MaxSpeed,
+		WithLogger(discardLogger), WithCheckpoint(cp))
+	defer r.Close()
+	if seq, _, _ := r.GetSnapshot(); seq != 0 {
+		t.Errorf("Expected checkpoint for another pair to be ignored, got sequence %d", seq)
+	}
+}
+
+func TestReplayFromCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl.gz")
+
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{
+		`{"sequence":"10","delete_update":{"order_id":"b1"}}`,
+		`{"sequence":"11","create_update":{"order_id":"b2","type":"BID","price":"99","volume":"3"}}`,
+		`{"sequence":"12","delete_update":{"order_id":"a1"}}`,
+	} {
+		if err := rec.record(time.Now(), "XBTZAR", []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r := NewReplay(f, "XBTZAR", MaxSpeed, WithLogger(discardLogger),
+		WithCheckpoint(testCheckpoint(t)))
+	defer r.Close()
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update 10 is already in the checkpoint, so b1 remains.
+	seq, bids, asks := r.Depth(-1)
+	if seq != 12 || len(asks) != 0 ||
+		!reflect.DeepEqual([]string{"100@1.00000000", "99@3.00000000"}, levelStrings(bids)) {
+		t.Errorf("Expected updates applied to the checkpoint, got %d %v %v", seq, bids, asks)
+	}
+}
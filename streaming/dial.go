@@ -0,0 +1,169 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+	"golang.org/x/net/websocket"
+)
+
+// ProxyFunc returns the URL of the proxy to use for a request, or nil for a
+// direct connection. http.ProxyFromEnvironment and http.ProxyURL are
+// ProxyFuncs.
+type ProxyFunc func(*http.Request) (*url.URL, error)
+
+// dialWebsocket opens the websocket described by config, through the proxy
+// returned by proxyFunc if there is one.
+func dialWebsocket(ctx context.Context, config *websocket.Config,
+	proxyFunc ProxyFunc) (*websocket.Conn, error) {
+
+	var proxyURL *url.URL
+	if proxyFunc != nil {
+		// Proxy functions like http.ProxyFromEnvironment only know about
+		// http and https.
+		u := *config.Location
+		u.Scheme = "http"
+		if config.Location.Scheme == "wss" {
+			u.Scheme = "https"
+		}
+		var err error
+		proxyURL, err = proxyFunc(&http.Request{URL: &u})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if proxyURL == nil {
+		return config.DialContext(ctx)
+	}
+
+	conn, err := dialProxy(ctx, config, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Interrupt the handshake if ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if config.Location.Scheme == "wss" {
+		tlsConfig := config.TlsConfig
+		if tlsConfig == nil {
+			tlsConfig = new(tls.Config)
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = config.Location.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// dialProxy opens a connection to the websocket's host through an http or
+// socks5 proxy.
+func dialProxy(ctx context.Context, config *websocket.Config,
+	proxyURL *url.URL) (net.Conn, error) {
+
+	dialer := config.Dialer
+	if dialer == nil {
+		dialer = new(net.Dialer)
+	}
+	addr := hostPort(config.Location)
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+
+	case "http":
+		conn, err := dialer.DialContext(ctx, "tcp", hostPort(proxyURL))
+		if err != nil {
+			return nil, err
+		}
+		if err := connectProxy(ctx, conn, proxyURL, addr); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("streaming: unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+// connectProxy asks the http proxy at the other end of conn to tunnel to addr.
+func connectProxy(ctx context.Context, conn net.Conn, proxyURL *url.URL,
+	addr string) error {
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		pass, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("streaming: proxy CONNECT failed: %s", res.Status)
+	}
+	return nil
+}
+
+// hostPort returns the host and port of u, using the scheme's default port if
+// u has none.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch u.Scheme {
+	case "wss", "https":
+		return net.JoinHostPort(u.Hostname(), "443")
+	case "socks5", "socks5h":
+		return net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
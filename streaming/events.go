@@ -0,0 +1,85 @@
+package streaming
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is the type of a connection state Event.
+type EventType int
+
+const (
+	// EventConnecting is sent before each connection attempt.
+	EventConnecting EventType = iota + 1
+	// EventConnected is sent when the websocket is established and
+	// authenticated. The order book is empty until the snapshot arrives.
+	EventConnected
+	// EventSnapshotReceived is sent when an order book snapshot has been
+	// applied. The book is live from then until the next EventDisconnected.
+	EventSnapshotReceived
+	// EventDisconnected is sent when a connection attempt fails or an
+	// established connection is lost. The order book has been reset.
+	EventDisconnected
+	// EventBackoff is sent when waiting before the next connection attempt.
+	EventBackoff
+	// EventResyncing is sent when an update is missed, or violates an order
+	// book invariant with WithInvariantResync, and the connection
+	// resubscribes for a fresh snapshot. The order book is stale until the
+	// next EventResynced or EventDisconnected.
+	EventResyncing
+	// EventResynced is sent when the fresh snapshot has been applied, along
+	// with the updates buffered while waiting for it. The book is live again.
+	EventResynced
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventConnecting:
+		return "Connecting"
+	case EventConnected:
+		return "Connected"
+	case EventSnapshotReceived:
+		return "SnapshotReceived"
+	case EventDisconnected:
+		return "Disconnected"
+	case EventBackoff:
+		return "Backoff"
+	case EventResyncing:
+		return "Resyncing"
+	case EventResynced:
+		return "Resynced"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event reports a change in the state of a Connection.
+type Event struct {
+	Type EventType
+	Pair string
+	Time time.Time
+
+	// Err is the cause of an EventDisconnected. It is ErrConnectionClosed if
+	// the connection was closed. For an EventResyncing caused by an order
+	// book invariant violation, it is the *InvariantError.
+	Err error
+	// Wait is the time until the next connection attempt, for EventBackoff.
+	Wait time.Duration
+	// Sequence is the sequence number of the snapshot for an
+	// EventSnapshotReceived. For an EventResyncing, it is that of the last
+	// update applied, or of the update which violated an invariant. For an
+	// EventResynced, it is that of the book after replaying updates.
+	Sequence int64
+}
+
+// EventCallback is called with connection state events.
+type EventCallback func(Event)
+
+// emit sends an event to the connection's event callback, if any.
+func (c *Connection) emit(e Event) {
+	if c.eventCallback == nil {
+		return
+	}
+	e.Pair = c.pair
+	e.Time = time.Now()
+	c.eventCallback(e)
+}
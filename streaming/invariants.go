@@ -0,0 +1,162 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+var (
+	// ErrCrossedBook is the cause of an InvariantError when the best bid is
+	// at or above the best ask.
+	ErrCrossedBook = errors.New("streaming: crossed order book")
+	// ErrInvalidOrder is the cause of an InvariantError when an order has a
+	// price or volume which is not positive.
+	ErrInvalidOrder = errors.New("streaming: invalid order")
+	// ErrInconsistentBook is the cause of an InvariantError when the order
+	// book's price levels do not agree with their orders.
+	ErrInconsistentBook = errors.New("streaming: inconsistent order book")
+)
+
+// InvariantError is a violation of the order book's invariants, found by
+// WithInvariantCheck.
+type InvariantError struct {
+	// Sequence is the sequence number of the snapshot or update after which
+	// the violation was found.
+	Sequence int64
+	// Err is ErrCrossedBook, ErrInvalidOrder or ErrInconsistentBook.
+	Err error
+	// Detail describes the violation.
+	Detail string
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("%v at sequence %d: %s", e.Err, e.Sequence, e.Detail)
+}
+
+func (e *InvariantError) Unwrap() error {
+	return e.Err
+}
+
+// InvariantCallback is called with violations of the order book's
+// invariants.
+type InvariantCallback func(*InvariantError)
+
+// invariantViolated counts and logs a violation before passing it to the
+// connection's invariant callback, if any.
+func (c *Connection) invariantViolated(e *InvariantError) {
+	atomic.AddInt64(&c.stats.Violations, 1)
+	c.log(luno.LevelWarn, "Order book invariant violated",
+		luno.Field{Key: "error", Value: e})
+	if c.invariantCallback != nil {
+		c.invariantCallback(e)
+	}
+}
+
+// checkUpdate checks the invariants which an update can break: that the book
+// is not crossed and that the order created by u, if any, is valid.
+func (ob *orderbookState) checkUpdate(u UpdateMessage) *InvariantError {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if u.CreateUpdate != nil {
+		if o, ok := ob.orders[u.CreateUpdate.OrderID]; ok {
+			if e := ob.checkOrder(o); e != nil {
+				return e
+			}
+		}
+	}
+	return ob.checkCrossed()
+}
+
+// checkAll checks every invariant of the book.
+func (ob *orderbookState) checkAll() *InvariantError {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if ob.seq == 0 {
+		return nil
+	}
+	n := 0
+	for _, s := range []*bookSide{ob.bids, ob.asks} {
+		if e := ob.checkSide(s); e != nil {
+			return e
+		}
+		n += s.orders
+	}
+	if n != len(ob.orders) {
+		return ob.violation(ErrInconsistentBook,
+			"%d orders in levels, %d by ID", n, len(ob.orders))
+	}
+	return ob.checkCrossed()
+}
+
+// checkSide checks that s's levels are in price order and that their counts
+// and volumes match their orders.
+func (ob *orderbookState) checkSide(s *bookSide) *InvariantError {
+	levels, orders := 0, 0
+	var prev *priceLevel
+	for l := s.best(); l != nil; prev, l = l, l.next[0] {
+		if prev != nil && !s.better(prev.price, l.price) {
+			return ob.violation(ErrInconsistentBook,
+				"level %s follows level %s", l.price, prev.price)
+		}
+		count, volume := 0, decimal.Zero()
+		for o := l.first; o != nil; o = o.next {
+			if e := ob.checkOrder(o); e != nil {
+				return e
+			}
+			if o.level != l || o.Price.Cmp(l.price) != 0 {
+				return ob.violation(ErrInconsistentBook,
+					"order %s at %s is in level %s", o.ID, o.Price, l.price)
+			}
+			count++
+			volume = volume.Add(o.Volume)
+		}
+		if count == 0 || count != l.count || volume.Cmp(l.volume) != 0 {
+			return ob.violation(ErrInconsistentBook,
+				"level %s has %d orders of volume %s, recorded as %d of %s",
+				l.price, count, volume, l.count, l.volume)
+		}
+		levels++
+		orders += count
+	}
+	if levels != s.levels || orders != s.orders {
+		return ob.violation(ErrInconsistentBook,
+			"side has %d levels and %d orders, recorded as %d and %d",
+			levels, orders, s.levels, s.orders)
+	}
+	return nil
+}
+
+// checkOrder checks that o has a positive price and volume.
+func (ob *orderbookState) checkOrder(o *bookOrder) *InvariantError {
+	if o.Price.Sign() <= 0 || o.Volume.Sign() <= 0 {
+		return ob.violation(ErrInvalidOrder,
+			"order %s has price %s and volume %s", o.ID, o.Price, o.Volume)
+	}
+	return nil
+}
+
+// checkCrossed checks that the best bid is below the best ask.
+func (ob *orderbookState) checkCrossed() *InvariantError {
+	bid, ask := ob.bids.best(), ob.asks.best()
+	if bid != nil && ask != nil && bid.price.Cmp(ask.price) >= 0 {
+		return ob.violation(ErrCrossedBook,
+			"best bid %s, best ask %s", bid.price, ask.price)
+	}
+	return nil
+}
+
+func (ob *orderbookState) violation(err error, format string,
+	args ...interface{}) *InvariantError {
+
+	return &InvariantError{
+		Sequence: ob.seq,
+		Err:      err,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,239 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCheckAll(t *testing.T) {
+	type testCase struct {
+		name    string
+		bids    []*order
+		asks    []*order
+		corrupt func(ob *orderbookState)
+		exp     error
+	}
+
+	testCases := []testCase{
+		{
+			name: "valid",
+			bids: []*order{testOrder(t, "b1", "100", "1"), testOrder(t, "b2", "100", "2")},
+			asks: []*order{testOrder(t, "a1", "101", "1")},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name: "crossed",
+			bids: []*order{testOrder(t, "b1", "101", "1")},
+			asks: []*order{testOrder(t, "a1", "101", "1")},
+			exp:  ErrCrossedBook,
+		},
+		{
+			name: "zero price",
+			bids: []*order{testOrder(t, "b1", "0", "1")},
+			exp:  ErrInvalidOrder,
+		},
+		{
+			name: "zero volume",
+			asks: []*order{testOrder(t, "a1", "101", "0")},
+			exp:  ErrInvalidOrder,
+		},
+		{
+			name: "level volume",
+			bids: []*order{testOrder(t, "b1", "100", "1")},
+			corrupt: func(ob *orderbookState) {
+				ob.bids.best().volume = dec(t, "2")
+			},
+			exp: ErrInconsistentBook,
+		},
+		{
+			name: "lost order",
+			bids: []*order{testOrder(t, "b1", "100", "1")},
+			corrupt: func(ob *orderbookState) {
+				delete(ob.orders, "b1")
+			},
+			exp: ErrInconsistentBook,
+		},
+	}
+
+	for _, tc := range testCases {
+		var ob orderbookState
+		ob.Set(5, tc.bids, tc.asks)
+		if tc.corrupt != nil {
+			tc.corrupt(&ob)
+		}
+		e := ob.checkAll()
+		if tc.exp == nil {
+			if e != nil {
+				t.Errorf("%s: expected no violation, got %v", tc.name, e)
+			}
+			continue
+		}
+		if e == nil || !errors.Is(e, tc.exp) || e.Sequence != 5 {
+			t.Errorf("%s: expected %v at sequence 5, got %v", tc.name, tc.exp, e)
+		}
+	}
+}
+
+// violationRecorder records the violations passed to an InvariantCallback.
+type violationRecorder struct {
+	mu         sync.Mutex
+	violations []*InvariantError
+}
+
+func (r *violationRecorder) record(e *InvariantError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.violations = append(r.violations, e)
+}
+
+func (r *violationRecorder) get() []*InvariantError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*InvariantError(nil), r.violations...)
+}
+
+func TestInvariantCheck(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{testOrder(t, "b1", "100", "1")},
+		[]*order{testOrder(t, "a1", "101", "1")})
+
+	var r violationRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithInvariantCheck(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "b2", Type: "BID", Price: dec(t, "101"), Volume: dec(t, "1"),
+	}})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "a2", Type: "ASK", Price: dec(t, "102"), Volume: dec(t, "0"),
+	}})
+	waitForSeq(t, c, 3)
+	waitFor(t, "violations", func() bool { return len(r.get()) == 2 })
+
+	v := r.get()
+	if v[0].Sequence != 2 || v[0].Err != ErrCrossedBook {
+		t.Errorf("Expected crossed book at 2, got %v", v[0])
+	}
+	if v[1].Sequence != 3 || v[1].Err != ErrInvalidOrder {
+		t.Errorf("Expected invalid order at 3, got %v", v[1])
+	}
+
+	// Without WithInvariantResync the book is left as it is.
+	stats := c.Stats()
+	if stats.Violations != 2 || stats.Resyncs != 0 || srv.connections() != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestInvariantCheckSnapshot(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{testOrder(t, "b1", "102", "1")},
+		[]*order{testOrder(t, "a1", "101", "1")})
+
+	var r violationRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithInvariantCheck(r.record), WithInvariantResync(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	v := r.get()
+	if len(v) != 1 || v[0].Sequence != 1 || v[0].Err != ErrCrossedBook {
+		t.Errorf("Expected crossed snapshot, got %v", v)
+	}
+	if n := srv.connections(); n != 1 {
+		t.Errorf("Expected no resync after a snapshot, got %d subscriptions", n)
+	}
+}
+
+func TestInvariantResync(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{testOrder(t, "b1", "100", "1")},
+		[]*order{testOrder(t, "a1", "101", "1")})
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithEventCallback(r.record), WithInvariantResync(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The delete of a1 was missed, so update 3 crosses the book.
+	srv.setSnapshot("XBTZAR", 3, []*order{
+		testOrder(t, "b1", "100", "1"),
+		testOrder(t, "b3", "101", "1"),
+	}, nil)
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "b3", Type: "BID", Price: dec(t, "101"), Volume: dec(t, "1"),
+	}})
+	waitFor(t, "resync", func() bool { return c.Stats().Resyncs == 1 })
+
+	seq, bids, asks := c.Depth(-1)
+	if exp, act := []string{"101@1.00000000", "100@1.00000000"}, levelStrings(bids); seq != 3 ||
+		!reflect.DeepEqual(exp, act) || len(asks) != 0 {
+		t.Errorf("Expected resynced book, got %d %v %v", seq, bids, asks)
+	}
+
+	exp := []EventType{EventConnecting, EventConnected, EventSnapshotReceived,
+		EventResyncing, EventSnapshotReceived, EventResynced}
+	events := r.get()
+	if act := eventTypes(events); !reflect.DeepEqual(exp, act) {
+		t.Fatalf("Expected events %v, got %v", exp, act)
+	}
+	var e *InvariantError
+	if !errors.As(events[3].Err, &e) || e.Err != ErrCrossedBook || events[3].Sequence != 3 {
+		t.Errorf("Expected resync for crossed book at 3, got %+v", events[3])
+	}
+	if stats := c.Stats(); stats.Violations != 1 || stats.Gaps != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestInvariantResyncDisabled(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, nil, []*order{testOrder(t, "a1", "101", "1")})
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger),
+		WithEventCallback(r.record), WithInvariantResync(true), WithResync(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "b1", Type: "BID", Price: dec(t, "101"), Volume: dec(t, "1"),
+	}})
+	waitFor(t, "disconnection", func() bool { return len(r.get()) >= 4 })
+	if e := r.get()[3]; e.Type != EventDisconnected || !errors.Is(e.Err, ErrCrossedBook) {
+		t.Errorf("Expected disconnection by crossed book, got %+v", e)
+	}
+}
@@ -0,0 +1,134 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/luno/luno-go"
+)
+
+// Manager streams the order books of many pairs with one set of credentials
+// and options. Pairs can be added and removed at any time.
+//
+// The streaming API serves one pair per websocket, so the Manager keeps a
+// Connection for each pair. The connections share a reconnection backoff, so
+// that they back off together when the service is unavailable instead of
+// retrying independently.
+type Manager struct {
+	keyID, keySecret string
+	opts             []DialOption
+	backoff          Backoff
+
+	mu     sync.Mutex
+	conns  map[string]*Connection
+	closed bool
+}
+
+// ErrManagerClosed is returned when adding a pair to a closed Manager.
+var ErrManagerClosed = errors.New("streaming: manager closed")
+
+// NewManager returns a Manager which dials connections with the given
+// credentials and options. Use WithPairUpdateCallback rather than
+// WithUpdateCallback to tell the updates of different pairs apart. The
+// connections share the backoff b, or a default one if b is nil, in place of
+// any set with WithBackoff.
+func NewManager(keyID, keySecret string, b Backoff, opts ...DialOption) (*Manager, error) {
+	if keyID == "" || keySecret == "" {
+		return nil, errors.New("streaming: streaming API requires credentials")
+	}
+	if b == nil {
+		b = new(backoff)
+	}
+
+	return &Manager{
+		keyID:     keyID,
+		keySecret: keySecret,
+		opts:      opts,
+		backoff:   b,
+		conns:     make(map[string]*Connection),
+	}, nil
+}
+
+// Add starts streaming pair. Adding a pair which is already streamed does
+// nothing.
+func (m *Manager) Add(pair string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrManagerClosed
+	}
+	if _, ok := m.conns[pair]; ok {
+		return nil
+	}
+
+	opts := append(append([]DialOption(nil), m.opts...), WithBackoff(m.backoff))
+	c, err := Dial(m.keyID, m.keySecret, pair, opts...)
+	if err != nil {
+		return err
+	}
+	m.conns[pair] = c
+	return nil
+}
+
+// Remove stops streaming pair and closes its connection.
+func (m *Manager) Remove(pair string) {
+	m.mu.Lock()
+	c, ok := m.conns[pair]
+	delete(m.conns, pair)
+	m.mu.Unlock()
+
+	if ok {
+		c.Close()
+	}
+}
+
+// Pairs returns the streamed pairs in alphabetical order.
+func (m *Manager) Pairs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pairs := make([]string, 0, len(m.conns))
+	for p := range m.conns {
+		pairs = append(pairs, p)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Connection returns the connection streaming pair, if any.
+func (m *Manager) Connection(pair string) (*Connection, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.conns[pair]
+	return c, ok
+}
+
+// GetSnapshot returns the order book of pair like Connection.GetSnapshot. It
+// returns an error if pair isn't streamed.
+func (m *Manager) GetSnapshot(pair string) (
+	int64, []luno.OrderBookEntry, []luno.OrderBookEntry, error) {
+
+	c, ok := m.Connection(pair)
+	if !ok {
+		return 0, nil, nil, fmt.Errorf("streaming: pair %s not streamed", pair)
+	}
+	seq, bids, asks := c.GetSnapshot()
+	return seq, bids, asks, nil
+}
+
+// Close closes all connections. The Manager can't be used afterwards.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	conns := m.conns
+	m.conns = make(map[string]*Connection)
+	m.closed = true
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
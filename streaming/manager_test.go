@@ -0,0 +1,94 @@
+package streaming
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+
+	srv.setSnapshot("XBTZAR", 10, []*order{testOrder(t, "b1", "100", "1")}, nil)
+	srv.setSnapshot("ETHZAR", 20, nil, []*order{testOrder(t, "a1", "50", "2")})
+
+	var mu sync.Mutex
+	updates := make(map[string]int)
+	m, err := NewManager("key", "secret", nil, srv.host(), WithPairUpdateCallback(
+		func(pair string, u UpdateMessage) {
+			mu.Lock()
+			updates[pair]++
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, pair := range []string{"XBTZAR", "ETHZAR", "XBTZAR"} {
+		if err := m.Add(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp, act := []string{"ETHZAR", "XBTZAR"}, m.Pairs(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected pairs %v, got %v", exp, act)
+	}
+
+	waitFor(t, "snapshots", func() bool {
+		s1, _, _, _ := m.GetSnapshot("XBTZAR")
+		s2, _, _, _ := m.GetSnapshot("ETHZAR")
+		return s1 == 10 && s2 == 20
+	})
+
+	srv.send("ETHZAR", UpdateMessage{
+		Sequence:     21,
+		DeleteUpdate: &DeleteUpdateMessage{OrderID: "a1"},
+	})
+	waitFor(t, "update", func() bool {
+		seq, _, asks, _ := m.GetSnapshot("ETHZAR")
+		return seq == 21 && len(asks) == 0
+	})
+	mu.Lock()
+	if updates["ETHZAR"] != 1 || updates["XBTZAR"] != 0 {
+		t.Errorf("Expected one ETHZAR update, got %v", updates)
+	}
+	mu.Unlock()
+
+	c, _ := m.Connection("XBTZAR")
+	if c2, _ := m.Connection("ETHZAR"); c.backoff != m.backoff || c2.backoff != m.backoff {
+		t.Errorf("Expected connections to share the manager's backoff")
+	}
+
+	m.Remove("XBTZAR")
+	if _, _, _, err := m.GetSnapshot("XBTZAR"); err == nil {
+		t.Errorf("Expected error for removed pair")
+	}
+	if c.ctx.Err() == nil {
+		t.Errorf("Expected removed connection to be closed")
+	}
+
+	m.Close()
+	if err := m.Add("XBTZAR"); err != ErrManagerClosed {
+		t.Errorf("Expected ErrManagerClosed, got %v", err)
+	}
+}
+
+func TestManagerBackoff(t *testing.T) {
+	b := ConstantBackoff(time.Millisecond)
+	var applied int
+	m, err := NewManager("key", "secret", b, func(*Connection) { applied++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if applied != 0 {
+		t.Errorf("Expected options to be applied only when dialing, got %d calls", applied)
+	}
+
+	if m.backoff == nil || m.backoff.Next(time.Now()) != time.Millisecond {
+		t.Errorf("Expected the manager to use the given backoff")
+	}
+}
@@ -3,36 +3,69 @@ package streaming
 import (
 	"encoding/json"
 	"errors"
-	"sort"
+	"sync/atomic"
 
 	"github.com/luno/luno-go"
 )
 
-func flatten(m map[string]order, reverse bool) []luno.OrderBookEntry {
-	var ol []luno.OrderBookEntry
-	for _, o := range m {
-		ol = append(ol, luno.OrderBookEntry{
-			Price:  o.Price,
-			Volume: o.Volume,
-		})
-	}
-	if reverse {
-		sort.Sort(sort.Reverse(orderList(ol)))
-	} else {
-		sort.Sort(orderList(ol))
-	}
-	return ol
-}
-
 type UpdateCallback func(UpdateMessage)
 
+// errSequenceGap is returned by HandleMessage when an update is missing.
+var errSequenceGap = errors.New("streaming: update received out of sequence")
+
+// errResyncOverflow is returned by HandleMessage when too many updates arrive
+// while waiting for a snapshot to resync.
+var errResyncOverflow = errors.New("streaming: too many updates while resyncing")
+
+// maxResyncBuffer is the most updates buffered while resyncing.
+const maxResyncBuffer = 10000
+
 type messageProcessor struct {
 	orderbook      orderbookState
 	updateCallback UpdateCallback
+	tradeCallback  TradeCallback
+
+	// publish sends updates to the connection's subscribers.
+	publish func(UpdateMessage)
+
+	// checkInvariants is set to check the order book after each snapshot and
+	// update, passing violations to invariantCallback. invariantResync is set
+	// to resync after an update violates them.
+	checkInvariants   bool
+	invariantResync   bool
+	invariantCallback func(*InvariantError)
+
+	// checkSnapshot, if set, is called before an order book snapshot is
+	// applied. A snapshot it returns an error for is not applied.
+	checkSnapshot func(seq int64) error
+
+	// snapshotCallback is called after an order book snapshot is applied.
+	snapshotCallback func(seq int64)
+
+	// live is 1 while the order book follows the stream: after a snapshot
+	// has been applied and until the next reset or resync. A book seeded
+	// from a checkpoint is not live. It is accessed atomically.
+	live int32
+
+	// lastTimestamp is the timestamp in unix milliseconds of the last
+	// update, or 0.
+	lastTimestamp int64
+
+	// resyncing is set after a sequence gap until the next snapshot. Updates
+	// received meanwhile are buffered, and those after the snapshot are
+	// replayed on top of it. replayed is the number of updates replayed after
+	// the last snapshot.
+	resyncing bool
+	buffer    []UpdateMessage
+	replayed  int
 }
 
 func (m *messageProcessor) Reset() {
+	m.setLive(false)
 	m.orderbook.Reset()
+	m.lastTimestamp = 0
+	m.resyncing = false
+	m.buffer = nil
 }
 
 func (m *messageProcessor) HandleMessage(message []byte) error {
@@ -45,46 +78,137 @@ func (m *messageProcessor) HandleMessage(message []byte) error {
 		return err
 	}
 	if ob.Asks != nil || ob.Bids != nil {
+		if m.checkSnapshot != nil {
+			if err := m.checkSnapshot(ob.Sequence); err != nil {
+				return err
+			}
+		}
 		m.orderbook.Set(ob.Sequence, ob.Bids, ob.Asks)
-		return nil
+		if m.snapshotCallback != nil {
+			m.snapshotCallback(ob.Sequence)
+		}
+		if m.checkInvariants {
+			// Resyncing would only fetch another snapshot from the same
+			// source, so violations here are just reported.
+			if e := m.orderbook.checkAll(); e != nil {
+				m.invariantCallback(e)
+			}
+		}
+		err := m.replay()
+		m.setLive(!m.resyncing)
+		return err
 	}
 
 	var u UpdateMessage
 	if err := json.Unmarshal(message, &u); err != nil {
 		return err
 	}
-	if err := m.receivedUpdate(u); err != nil {
-		return err
+	m.lastTimestamp = u.Timestamp
+
+	if m.resyncing {
+		if len(m.buffer) >= maxResyncBuffer {
+			return errResyncOverflow
+		}
+		m.buffer = append(m.buffer, u)
+		return nil
+	}
+
+	err := m.receivedUpdate(u)
+	if err == errSequenceGap {
+		m.setResyncing()
+		m.buffer = append(m.buffer[:0], u)
+	} else if _, ok := err.(*InvariantError); ok {
+		// The update was applied, so only later ones are buffered.
+		m.setResyncing()
+		m.buffer = m.buffer[:0]
+	}
+	return err
+}
+
+// setResyncing starts buffering updates until the next snapshot.
+func (m *messageProcessor) setResyncing() {
+	m.resyncing = true
+	m.setLive(false)
+}
+
+func (m *messageProcessor) setLive(live bool) {
+	var v int32
+	if live {
+		v = 1
 	}
+	atomic.StoreInt32(&m.live, v)
+}
+
+// isLive reports whether the order book follows the stream. It may be called
+// from any goroutine.
+func (m *messageProcessor) isLive() bool {
+	return atomic.LoadInt32(&m.live) == 1
+}
 
+// replay applies the updates buffered while resyncing which follow the
+// snapshot just set. If the snapshot is missing updates, or an update
+// violates the book's invariants, the processor stays resyncing, buffering
+// the updates not yet applied.
+func (m *messageProcessor) replay() error {
+	buffer := m.buffer
+	m.resyncing = false
+	m.buffer = nil
+	m.replayed = 0
+
+	for i, u := range buffer {
+		if u.Sequence <= m.orderbook.seq {
+			continue
+		}
+		err := m.receivedUpdate(u)
+		if err == errSequenceGap {
+			m.resyncing = true
+			m.buffer = append([]UpdateMessage(nil), buffer[i:]...)
+			return err
+		} else if _, ok := err.(*InvariantError); ok {
+			m.resyncing = true
+			m.buffer = append([]UpdateMessage(nil), buffer[i+1:]...)
+			return err
+		} else if err != nil {
+			return err
+		}
+		m.replayed++
+	}
 	return nil
 }
 
-func (m *messageProcessor) receivedUpdate(u UpdateMessage) error {
+// applyUpdate applies u to the order book, returning whether it was applied
+// and the trades it made, if there is a trade callback.
+func (m *messageProcessor) applyUpdate(u UpdateMessage) (bool, []Trade, error) {
 	m.orderbook.Lock()
 	defer m.orderbook.Unlock()
 
 	if m.orderbook.GetStateId() == 0 {
-		return nil
+		return false, nil, nil
 	}
 
 	if u.Sequence <= m.orderbook.GetStateId() {
-		return nil
+		return false, nil, nil
 	}
 
 	if u.Sequence != m.orderbook.GetStateId()+1 {
-		return errors.New("streaming: update received out of sequence")
+		return false, nil, errSequenceGap
 	}
 
+	var trades []Trade
 	for _, t := range u.TradeUpdates {
+		if m.tradeCallback != nil {
+			if tr, ok := m.orderbook.makeTrade(u, *t); ok {
+				trades = append(trades, tr)
+			}
+		}
 		if err := m.processTrade(*t); err != nil {
-			return err
+			return false, nil, err
 		}
 	}
 
 	if u.CreateUpdate != nil {
 		if err := m.processCreate(*u.CreateUpdate); err != nil {
-			return err
+			return false, nil, err
 		}
 	}
 
@@ -93,11 +217,40 @@ func (m *messageProcessor) receivedUpdate(u UpdateMessage) error {
 	}
 
 	m.orderbook.SetStateId(u.Sequence)
+	return true, trades, nil
+}
+
+// receivedUpdate applies u and then, without the order book locked, passes it
+// and its trades to the callbacks and subscribers. It returns an
+// *InvariantError if u violates the book's invariants and the processor
+// resyncs on violations.
+func (m *messageProcessor) receivedUpdate(u UpdateMessage) error {
+	applied, trades, err := m.applyUpdate(u)
+	if err != nil || !applied {
+		return err
+	}
+
+	for _, t := range trades {
+		m.tradeCallback(t)
+	}
 
 	if m.updateCallback != nil {
 		m.updateCallback(u)
 	}
 
+	if m.publish != nil {
+		m.publish(u)
+	}
+
+	if m.checkInvariants {
+		if e := m.orderbook.checkUpdate(u); e != nil {
+			m.invariantCallback(e)
+			if m.invariantResync {
+				return e
+			}
+		}
+	}
+
 	return nil
 }
 
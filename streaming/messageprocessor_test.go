@@ -500,7 +500,7 @@ func calculateOrderbookStatistics(sequence int64, bids []luno.OrderBookEntry, as
 	return stats
 }
 
-func loadFromFile(t *testing.T, name string) []byte {
+func loadFromFile(t testing.TB, name string) []byte {
 	path := filepath.Join("testdata", name) // relative path
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -508,3 +508,125 @@ func loadFromFile(t *testing.T, name string) []byte {
 	}
 	return bytes
 }
+
+func TestHandleMessageResync(t *testing.T) {
+	var updates []int64
+	mp := &messageProcessor{
+		updateCallback: func(u UpdateMessage) {
+			updates = append(updates, u.Sequence)
+		},
+	}
+
+	mp.HandleMessage(loadFromFile(t, "fixture_orderbook.json"))
+	err := mp.HandleMessage([]byte(`{"sequence":"40413240","delete_update":{"order_id":"BXNC7TGBBJJ885S"},"timestamp":1530887350936}`))
+	if err != errSequenceGap {
+		t.Fatalf("Expected errSequenceGap, got %v", err)
+	}
+	if !mp.resyncing {
+		t.Fatalf("Expected to be resyncing")
+	}
+	err = mp.HandleMessage([]byte(`{"sequence":"40413241","delete_update":{"order_id":"BXEMZSYBRFYHSCF"},"timestamp":1530887350937}`))
+	if err != nil {
+		t.Fatalf("Expected update to be buffered, got %v", err)
+	}
+
+	// The fresh snapshot is at 40413239, so both buffered updates are replayed.
+	if err := mp.HandleMessage([]byte(`{"sequence":"40413239","asks":[{"id":"BXEMZSYBRFYHSCF","price":"92655.00","volume":"0.495769"}],"bids":[{"id":"BXNC7TGBBJJ885S","price":"92654.00","volume":"1"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, bids, asks := mp.orderbook.GetSnapshot()
+	if seq != 40413241 || len(bids) != 0 || len(asks) != 0 {
+		t.Errorf("Expected empty book at 40413241, got %d %v %v", seq, bids, asks)
+	}
+	if exp := []int64{40413240, 40413241}; !reflect.DeepEqual(exp, updates) {
+		t.Errorf("Expected updates %v, got %v", exp, updates)
+	}
+	if mp.resyncing || mp.replayed != 2 {
+		t.Errorf("Expected resync to finish with 2 updates replayed, got %v %d",
+			mp.resyncing, mp.replayed)
+	}
+}
+
+func TestHandleMessageResyncGap(t *testing.T) {
+	mp := &messageProcessor{}
+	mp.HandleMessage([]byte(`{"sequence":"1","asks":[],"bids":[]}`))
+	if err := mp.HandleMessage([]byte(`{"sequence":"3"}`)); err != errSequenceGap {
+		t.Fatalf("Expected errSequenceGap, got %v", err)
+	}
+	mp.HandleMessage([]byte(`{"sequence":"4"}`))
+
+	// The fresh snapshot is still missing update 2.
+	if err := mp.HandleMessage([]byte(`{"sequence":"1","asks":[],"bids":[]}`)); err != errSequenceGap {
+		t.Fatalf("Expected errSequenceGap, got %v", err)
+	}
+	if !mp.resyncing || len(mp.buffer) != 2 {
+		t.Fatalf("Expected to keep resyncing with 2 updates buffered, got %v %d",
+			mp.resyncing, len(mp.buffer))
+	}
+
+	if err := mp.HandleMessage([]byte(`{"sequence":"2","asks":[],"bids":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if seq, _, _ := mp.orderbook.GetSnapshot(); seq != 4 || mp.resyncing || mp.replayed != 2 {
+		t.Errorf("Expected resync to 4 with 2 updates replayed, got %d %v %d",
+			seq, mp.resyncing, mp.replayed)
+	}
+}
+
+func TestHandleMessageTrades(t *testing.T) {
+	var trades []Trade
+	var order []string
+	mp := &messageProcessor{
+		updateCallback: func(update UpdateMessage) {
+			order = append(order, "update")
+		},
+		tradeCallback: func(t Trade) {
+			trades = append(trades, t)
+			order = append(order, "trade")
+		},
+	}
+
+	mp.HandleMessage(loadFromFile(t, "fixture_orderbook.json"))
+	mp.HandleMessage([]byte(`{"sequence":"40413239","trade_updates":[{"base":"0.094976","counter":"8800.00128","maker_order_id":"BXEMZSYBRFYHSCF","taker_order_id":"BXGGSPFECZKFQ34","order_id":"BXEMZSYBRFYHSCF"},{"base":"1.834379","counter":"169962.55187","maker_order_id":"BXBAYA687URRT28","taker_order_id":"BXGGSPFECZKFQ35","order_id":"BXBAYA687URRT28"}],"create_update":null,"delete_update":null,"timestamp":1530887351827}`))
+
+	if exp := []string{"trade", "trade", "update"}; !reflect.DeepEqual(exp, order) {
+		t.Errorf("Expected callbacks %v, got %v", exp, order)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(trades))
+	}
+
+	type testCase struct {
+		trade               Trade
+		maker, taker, price string
+		side                luno.OrderType
+	}
+	for _, tc := range []testCase{
+		{trades[0], "BXEMZSYBRFYHSCF", "BXGGSPFECZKFQ34", "92655.00", luno.OrderTypeAsk},
+		{trades[1], "BXBAYA687URRT28", "BXGGSPFECZKFQ35", "92654.00", luno.OrderTypeBid},
+	} {
+		tr := tc.trade
+		if tr.MakerOrderID != tc.maker || tr.TakerOrderID != tc.taker {
+			t.Errorf("Expected orders %s/%s, got %s/%s",
+				tc.maker, tc.taker, tr.MakerOrderID, tr.TakerOrderID)
+		}
+		if tr.Price.String() != tc.price {
+			t.Errorf("Expected price %s, got %s", tc.price, tr.Price)
+		}
+		if tr.MakerSide != tc.side {
+			t.Errorf("Expected maker side %s, got %s", tc.side, tr.MakerSide)
+		}
+		if tr.Sequence != 40413239 || tr.Timestamp.UnixNano()/1e6 != 1530887351827 {
+			t.Errorf("Expected sequence and timestamp of the update, got %d %s",
+				tr.Sequence, tr.Timestamp)
+		}
+	}
+
+	// A failed update reports no trades.
+	trades = nil
+	mp.HandleMessage([]byte(`{"sequence":"40413240","trade_updates":[{"base":"100","counter":"1","order_id":"BXEKY2MVGYK2U3R"}],"timestamp":1530887351828}`))
+	if len(trades) != 0 {
+		t.Errorf("Expected no trades for a failed update, got %v", trades)
+	}
+}
@@ -15,9 +15,11 @@ type orderbookMessage struct {
 }
 
 type TradeUpdateMessage struct {
-	Base    decimal.Decimal `json:"base,string"`
-	Counter decimal.Decimal `json:"counter,string"`
-	OrderID string          `json:"order_id"`
+	Base         decimal.Decimal `json:"base,string"`
+	Counter      decimal.Decimal `json:"counter,string"`
+	OrderID      string          `json:"order_id"`
+	MakerOrderID string          `json:"maker_order_id"`
+	TakerOrderID string          `json:"taker_order_id"`
 }
 
 type CreateUpdateMessage struct {
@@ -1,12 +1,221 @@
 package streaming
 
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/luno/luno-go"
+)
+
 type DialOption func(*Connection)
 
 // WithUpdateCallback returns an options which sets a callback function for
 // streaming updates. Each update will first be applied to the order book, and
-// then passed to the callback function.
+// then passed to the callback function. The order book is not locked during
+// the callback, so it may query the connection, but the connection processes
+// no messages until it returns. Use Subscribe for slow consumers.
 func WithUpdateCallback(fn UpdateCallback) DialOption {
 	return func(c *Connection) {
 		c.MessageProcessor.updateCallback = fn
 	}
 }
+
+// WithLogger returns an option which sets the logger for connection events.
+// By default, messages at info level and above are written to the standard
+// logger.
+func WithLogger(logger luno.Logger) DialOption {
+	return func(c *Connection) {
+		c.logger = logger
+	}
+}
+
+// PairUpdateCallback is called with the streaming updates of a pair.
+type PairUpdateCallback func(pair string, u UpdateMessage)
+
+// WithPairUpdateCallback returns an option like WithUpdateCallback which also
+// passes the connection's pair to the callback. It is useful with a Manager,
+// whose connections all share the same options.
+func WithPairUpdateCallback(fn PairUpdateCallback) DialOption {
+	return func(c *Connection) {
+		pair := c.pair
+		c.MessageProcessor.updateCallback = func(u UpdateMessage) {
+			fn(pair, u)
+		}
+	}
+}
+
+// WithBackoff returns an option which sets how long to wait before
+// reconnecting. The default is NewExponentialBackoff.
+func WithBackoff(b Backoff) DialOption {
+	return func(c *Connection) {
+		c.backoff = b
+	}
+}
+
+// WithMaxRetries returns an option which closes the connection after n
+// consecutive failed connection attempts following the first, i.e. after n+1
+// attempts without an order book snapshot. WaitReady then returns
+// ErrMaxRetries. By default the connection retries forever.
+func WithMaxRetries(n int) DialOption {
+	return func(c *Connection) {
+		c.maxRetries = n
+	}
+}
+
+// WithHost returns an option which sets the websocket host, e.g.
+// "wss://ws.luno.com". The default is DefaultHost.
+func WithHost(host string) DialOption {
+	return func(c *Connection) {
+		c.host = host
+	}
+}
+
+// WithOrigin returns an option which sets the origin sent when opening the
+// websocket. The default is DefaultOrigin.
+func WithOrigin(origin string) DialOption {
+	return func(c *Connection) {
+		c.origin = origin
+	}
+}
+
+// WithDialer returns an option which sets the dialer used to open network
+// connections, e.g. to set a timeout or local address.
+func WithDialer(d *net.Dialer) DialOption {
+	return func(c *Connection) {
+		c.dialer = d
+	}
+}
+
+// WithTLSConfig returns an option which sets the TLS configuration used for
+// wss hosts.
+func WithTLSConfig(config *tls.Config) DialOption {
+	return func(c *Connection) {
+		c.tlsConfig = config
+	}
+}
+
+// WithProxy returns an option which connects through the proxy returned by
+// fn, e.g. http.ProxyFromEnvironment. http and socks5 proxies are supported.
+func WithProxy(fn ProxyFunc) DialOption {
+	return func(c *Connection) {
+		c.proxy = fn
+	}
+}
+
+// WithEventCallback returns an option which sets a callback for connection
+// state events, e.g. to stop trading while the order book is not live. The
+// callback is called synchronously from the connection's goroutine, so it
+// must not block or call Close.
+func WithEventCallback(fn EventCallback) DialOption {
+	return func(c *Connection) {
+		c.eventCallback = fn
+	}
+}
+
+// WithStaleTimeout returns an option which sets how long to wait for a
+// message from the streaming service before treating the connection as stale
+// and reconnecting. Updates whose timestamp lags by more than d also cause a
+// reconnect. A d of 0 disables the check. The default is
+// DefaultStaleTimeout.
+func WithStaleTimeout(d time.Duration) DialOption {
+	return func(c *Connection) {
+		c.staleTimeout = d
+	}
+}
+
+// WithResync returns an option which sets whether the connection recovers
+// from a missed update by resubscribing for a fresh snapshot, replaying the
+// updates received meanwhile, rather than reconnecting with a backoff. It is
+// enabled by default.
+func WithResync(enabled bool) DialOption {
+	return func(c *Connection) {
+		c.resync = enabled
+	}
+}
+
+// WithTradeCallback returns an option which sets a callback for the trades
+// executed in the order book. The trades of an update are passed to the
+// callback after the update has been applied, before the update callback.
+func WithTradeCallback(fn TradeCallback) DialOption {
+	return func(c *Connection) {
+		pair := c.pair
+		c.MessageProcessor.tradeCallback = func(t Trade) {
+			t.Pair = pair
+			fn(t)
+		}
+	}
+}
+
+// WithRecorder returns an option which records the raw messages received by
+// the connection with r.
+func WithRecorder(r *Recorder) DialOption {
+	return func(c *Connection) {
+		c.recorder = r
+	}
+}
+
+// WithCheckpoint returns an option which seeds the order book from cp, e.g.
+// one saved before a restart, so that it can be read before the first
+// snapshot arrives. The book is stale until then: WaitReady still waits for
+// the snapshot, which replaces the checkpoint. A snapshot older than the
+// checkpoint is rejected with ErrStaleSnapshot, and the connection reconnects
+// as it would after any other error, without the checkpoint. With NewReplay,
+// recorded updates which follow the checkpoint's sequence are applied to it.
+//
+// Invalid checkpoints, or those for another pair, are logged and ignored.
+func WithCheckpoint(cp Checkpoint) DialOption {
+	return func(c *Connection) {
+		c.checkpoint = &cp
+	}
+}
+
+// WithInvariantCheck returns an option which checks the order book after each
+// snapshot and update: that it is not crossed, that its orders have positive
+// prices and volumes, and, after snapshots, that its price levels agree with
+// their orders. Violations are logged, counted in Stats and passed to fn, if
+// not nil, from the connection's goroutine. The book is left as it is unless
+// WithInvariantResync is enabled.
+func WithInvariantCheck(fn InvariantCallback) DialOption {
+	return func(c *Connection) {
+		c.MessageProcessor.checkInvariants = true
+		c.invariantCallback = fn
+	}
+}
+
+// WithInvariantResync returns an option which enables WithInvariantCheck and
+// sets whether an update which violates the order book's invariants causes a
+// resync, as for a missed update: the connection resubscribes for a fresh
+// snapshot if WithResync is enabled, and reconnects otherwise. Violations
+// found in a snapshot are only reported.
+func WithInvariantResync(enabled bool) DialOption {
+	return func(c *Connection) {
+		c.MessageProcessor.checkInvariants = true
+		c.MessageProcessor.invariantResync = enabled
+	}
+}
+
+// WithAuditor returns an option which compares the order book with the REST
+// order book from client.GetOrderBook every interval, passing each report to
+// fn, if not nil, from the auditor's goroutine. Discrepancies are also logged.
+// The REST order book is not taken at the same sequence as the streamed one,
+// so small discrepancies are expected on busy pairs. It has no effect with
+// NewReplay, or if interval is not positive.
+func WithAuditor(client luno.API, interval time.Duration, fn AuditCallback) DialOption {
+	return func(c *Connection) {
+		c.auditClient = client
+		c.auditInterval = interval
+		c.auditCallback = fn
+	}
+}
+
+// WithAuditReset returns an option which resyncs the order book when an audit
+// by WithAuditor finds that the fraction of compared levels with
+// discrepancies exceeds threshold, e.g. 0 to resync on any discrepancy. The
+// connection resubscribes for a fresh snapshot if WithResync is enabled, and
+// reconnects otherwise.
+func WithAuditReset(threshold float64) DialOption {
+	return func(c *Connection) {
+		c.auditThreshold = threshold
+	}
+}
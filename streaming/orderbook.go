@@ -3,28 +3,215 @@ package streaming
 import (
 	"errors"
 	"fmt"
+	"math/big"
+	"math/rand"
 	"sync"
 
 	"github.com/luno/luno-go"
 	"github.com/luno/luno-go/decimal"
 )
 
-type orderList []luno.OrderBookEntry
+// bookOrder is an order in the book, linked into its price level.
+type bookOrder struct {
+	order
+	side       *bookSide
+	level      *priceLevel
+	prev, next *bookOrder
+}
+
+// priceLevel holds the orders at one price, in the order they were added,
+// and their total volume. It is a node of its side's skiplist.
+type priceLevel struct {
+	price       decimal.Decimal
+	volume      decimal.Decimal
+	count       int
+	first, last *bookOrder
+
+	next []*priceLevel
+}
+
+// maxHeight bounds the height of the skiplists, which stay balanced for up to
+// about 2^maxHeight price levels.
+const maxHeight = 20
+
+// bookSide is one side of the order book. Its price levels are kept in a
+// skiplist ordered from the best price, so the best level is found in O(1)
+// and any level in O(log n).
+type bookSide struct {
+	// desc is set for bids, whose best price is the highest.
+	desc bool
+
+	head   priceLevel
+	height int
+	levels int
+	orders int
+}
+
+func newBookSide(desc bool) *bookSide {
+	return &bookSide{
+		desc:   desc,
+		head:   priceLevel{next: make([]*priceLevel, maxHeight)},
+		height: 1,
+	}
+}
+
+// better reports whether price a is better than price b on this side.
+func (s *bookSide) better(a, b decimal.Decimal) bool {
+	if s.desc {
+		return a.Cmp(b) > 0
+	}
+	return a.Cmp(b) < 0
+}
+
+// search returns the level at price, if any. If update is not nil, it is
+// filled with the last level before price at each height.
+func (s *bookSide) search(price decimal.Decimal, update []*priceLevel) *priceLevel {
+	x := &s.head
+	for i := s.height - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.better(x.next[i].price, price) {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	x = x.next[0]
+	if x != nil && x.price.Cmp(price) == 0 {
+		return x
+	}
+	return nil
+}
+
+// randomHeight returns the height of a new level: h with probability 2^-h.
+func randomHeight() int {
+	h := 1
+	for r := rand.Int63(); h < maxHeight && r&1 == 1; r >>= 1 {
+		h++
+	}
+	return h
+}
+
+// best returns the level with the best price, or nil if the side is empty.
+func (s *bookSide) best() *priceLevel {
+	if s == nil {
+		return nil
+	}
+	return s.head.next[0]
+}
+
+// add appends o to the level at its price, creating the level if needed.
+func (s *bookSide) add(o *bookOrder) {
+	var update [maxHeight]*priceLevel
+	l := s.search(o.Price, update[:])
+	if l == nil {
+		h := randomHeight()
+		for ; s.height < h; s.height++ {
+			update[s.height] = &s.head
+		}
+		l = &priceLevel{
+			price:  o.Price,
+			volume: decimal.Zero(),
+			next:   make([]*priceLevel, h),
+		}
+		for i := 0; i < h; i++ {
+			l.next[i] = update[i].next[i]
+			update[i].next[i] = l
+		}
+		s.levels++
+	}
+
+	o.level = l
+	o.prev, o.next = l.last, nil
+	if l.last != nil {
+		l.last.next = o
+	} else {
+		l.first = o
+	}
+	l.last = o
+	l.count++
+	l.volume = l.volume.Add(o.Volume)
+	s.orders++
+}
+
+// remove removes o from its level, removing the level if it is left empty.
+func (s *bookSide) remove(o *bookOrder) {
+	l := o.level
+	if o.prev != nil {
+		o.prev.next = o.next
+	} else {
+		l.first = o.next
+	}
+	if o.next != nil {
+		o.next.prev = o.prev
+	} else {
+		l.last = o.prev
+	}
+	o.level, o.prev, o.next = nil, nil, nil
+	l.count--
+	l.volume = l.volume.Sub(o.Volume)
+	s.orders--
 
-func (ol orderList) Less(i, j int) bool {
-	return ol[i].Price.Cmp(ol[j].Price) < 0
+	if l.count > 0 {
+		return
+	}
+	var update [maxHeight]*priceLevel
+	s.search(l.price, update[:])
+	for i := range l.next {
+		if update[i].next[i] == l {
+			update[i].next[i] = l.next[i]
+		}
+	}
+	for s.height > 1 && s.head.next[s.height-1] == nil {
+		s.height--
+	}
+	s.levels--
 }
-func (ol orderList) Swap(i, j int) {
-	ol[i], ol[j] = ol[j], ol[i]
+
+// entries returns every order from the best price, or nil if there are none.
+func (s *bookSide) entries() []luno.OrderBookEntry {
+	if s == nil || s.orders == 0 {
+		return nil
+	}
+	ol := make([]luno.OrderBookEntry, 0, s.orders)
+	for l := s.best(); l != nil; l = l.next[0] {
+		for o := l.first; o != nil; o = o.next {
+			ol = append(ol, luno.OrderBookEntry{Price: o.Price, Volume: o.Volume})
+		}
+	}
+	return ol
 }
-func (ol orderList) Len() int {
-	return len(ol)
+
+// volumeTo returns the total volume of the levels from the best price up to
+// and including price.
+func (s *bookSide) volumeTo(price decimal.Decimal) decimal.Decimal {
+	v := decimal.Zero()
+	for l := s.best(); l != nil && !s.better(price, l.price); l = l.next[0] {
+		v = v.Add(l.volume)
+	}
+	return v
+}
+
+// depth returns the price and total volume of up to n levels from the best,
+// or of every level if n is negative.
+func (s *bookSide) depth(n int) []luno.OrderBookEntry {
+	if s == nil {
+		return nil
+	}
+	if n < 0 || n > s.levels {
+		n = s.levels
+	}
+	ol := make([]luno.OrderBookEntry, 0, n)
+	for l := s.best(); l != nil && len(ol) < n; l = l.next[0] {
+		ol = append(ol, luno.OrderBookEntry{Price: l.price, Volume: l.volume})
+	}
+	return ol
 }
 
 type orderbookState struct {
-	seq  int64
-	bids map[string]order
-	asks map[string]order
+	seq    int64
+	bids   *bookSide
+	asks   *bookSide
+	orders map[string]*bookOrder
 
 	mu sync.Mutex
 }
@@ -36,26 +223,37 @@ func (ob *orderbookState) Reset() {
 	ob.seq = 0
 	ob.bids = nil
 	ob.asks = nil
-}
-
-func convertOrders(ol []*order) map[string]order {
-	r := make(map[string]order)
-	for _, o := range ol {
-		r[o.ID] = *o
-	}
-	return r
+	ob.orders = nil
 }
 
 func (ob *orderbookState) Set(sequence int64, bids []*order, asks []*order) {
-	bidsMap := convertOrders(bids)
-	asksMap := convertOrders(asks)
+	bidSide := newBookSide(true)
+	askSide := newBookSide(false)
+	orders := make(map[string]*bookOrder, len(bids)+len(asks))
+	for _, o := range bids {
+		addOrder(orders, bidSide, *o)
+	}
+	for _, o := range asks {
+		addOrder(orders, askSide, *o)
+	}
 
 	ob.mu.Lock()
 	defer ob.mu.Unlock()
 
 	ob.seq = sequence
-	ob.bids = bidsMap
-	ob.asks = asksMap
+	ob.bids = bidSide
+	ob.asks = askSide
+	ob.orders = orders
+}
+
+// addOrder adds o to side, replacing any order with the same ID.
+func addOrder(orders map[string]*bookOrder, side *bookSide, o order) {
+	if prev, ok := orders[o.ID]; ok {
+		prev.side.remove(prev)
+	}
+	bo := &bookOrder{order: o, side: side}
+	side.add(bo)
+	orders[o.ID] = bo
 }
 
 func (ob *orderbookState) Lock() {
@@ -75,68 +273,163 @@ func (ob *orderbookState) SetStateId(seq int64) {
 }
 
 func (ob *orderbookState) DecrementOrder(id string, base decimal.Decimal) error {
-	ok, err := decTrade(ob.bids, id, base)
-	if err != nil {
-		return err
-	}
-	if ok {
-		return nil
+	o, ok := ob.orders[id]
+	if !ok {
+		return errors.New("streaming: trade for unknown order")
 	}
 
-	ok, err = decTrade(ob.asks, id, base)
-	if err != nil {
-		return err
-	}
-	if ok {
-		return nil
+	volume := o.Volume.Sub(base)
+	if volume.Sign() < 0 {
+		return fmt.Errorf("streaming: negative volume: %s", volume)
 	}
 
-	return errors.New("streaming: trade for unknown order")
+	if volume.Sign() == 0 {
+		o.side.remove(o)
+		delete(ob.orders, id)
+	} else {
+		o.Volume = volume
+		o.level.volume = o.level.volume.Sub(base)
+	}
+	return nil
 }
 
 func (ob *orderbookState) AddOrder(ordertype luno.OrderType, order order) {
 	if ordertype == luno.OrderTypeBid {
-		ob.bids[order.ID] = order
+		addOrder(ob.orders, ob.bids, order)
 	} else if ordertype == luno.OrderTypeAsk {
-		ob.asks[order.ID] = order
+		addOrder(ob.orders, ob.asks, order)
 	}
 }
 
 func (ob *orderbookState) RemoveOrder(id string) {
-	delete(ob.bids, id)
-	delete(ob.asks, id)
+	o, ok := ob.orders[id]
+	if !ok {
+		return
+	}
+	o.side.remove(o)
+	delete(ob.orders, id)
 }
 
-func decTrade(m map[string]order, id string, base decimal.Decimal) (
-	bool, error) {
+// OrderBookSnapshot returns the latest order book.
+func (ob *orderbookState) GetSnapshot() (
+	int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
 
-	o, ok := m[id]
-	if !ok {
-		return false, nil
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return ob.seq, ob.bids.entries(), ob.asks.entries()
+}
+
+// depth returns the price and total volume of up to n price levels on each
+// side of the book, from the best price.
+func (ob *orderbookState) depth(n int) (
+	int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return ob.seq, ob.bids.depth(n), ob.asks.depth(n)
+}
+
+// side returns the bids or asks.
+func (ob *orderbookState) side(t luno.OrderType) *bookSide {
+	if t == luno.OrderTypeBid {
+		return ob.bids
 	}
+	return ob.asks
+}
 
-	o.Volume = o.Volume.Add(base.Neg())
+// best returns the price and total volume of the best level of side t.
+func (ob *orderbookState) best(t luno.OrderType) (luno.OrderBookEntry, bool) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
 
-	if o.Volume.Sign() < 0 {
-		return false, fmt.Errorf("streaming: negative volume: %s", o.Volume)
+	l := ob.side(t).best()
+	if l == nil {
+		return luno.OrderBookEntry{}, false
 	}
+	return luno.OrderBookEntry{Price: l.price, Volume: l.volume}, true
+}
 
-	if o.Volume.Sign() == 0 {
-		delete(m, id)
-	} else {
-		m[id] = o
+// top returns the best bid and ask prices. ok is false if either side is
+// empty.
+func (ob *orderbookState) top() (bid, ask decimal.Decimal, ok bool) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	b, a := ob.bids.best(), ob.asks.best()
+	if b == nil || a == nil {
+		return decimal.Zero(), decimal.Zero(), false
 	}
-	return true, nil
+	return b.price, a.price, true
 }
 
-// OrderBookSnapshot returns the latest order book.
-func (ob *orderbookState) GetSnapshot() (
-	int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
+// half is 0.5, for computing midpoints exactly.
+var half = decimal.New(big.NewInt(5), 1)
+
+// spread returns the best ask price less the best bid price.
+func (ob *orderbookState) spread() (decimal.Decimal, bool) {
+	bid, ask, ok := ob.top()
+	if !ok {
+		return decimal.Zero(), false
+	}
+	return ask.Sub(bid), true
+}
+
+// midPrice returns the average of the best bid and ask prices.
+func (ob *orderbookState) midPrice() (decimal.Decimal, bool) {
+	bid, ask, ok := ob.top()
+	if !ok {
+		return decimal.Zero(), false
+	}
+	return bid.Add(ask).Mul(half), true
+}
+
+// volumeTo returns the total volume on side t at prices up to and including
+// price, from the best price.
+func (ob *orderbookState) volumeTo(t luno.OrderType, price decimal.Decimal) decimal.Decimal {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	s := ob.side(t)
+	if s == nil {
+		return decimal.Zero()
+	}
+	return s.volumeTo(price)
+}
+
+// marketLevels returns the levels a market order for base or counter volume
+// would reach, with the best level of the other side of the book. They are
+// returned as bids and asks for luno.EstimateMarketOrder.
+func (ob *orderbookState) marketLevels(typ luno.OrderType,
+	base, counter decimal.Decimal) ([]luno.OrderBookEntry, []luno.OrderBookEntry) {
 
 	ob.mu.Lock()
 	defer ob.mu.Unlock()
 
-	bids := flatten(ob.bids, true)
-	asks := flatten(ob.asks, false)
-	return ob.seq, bids, asks
+	take, other := ob.bids, ob.asks
+	if typ == luno.OrderTypeBuy {
+		take, other = ob.asks, ob.bids
+	}
+
+	var levels []luno.OrderBookEntry
+	sum := decimal.Zero()
+	for l := take.best(); l != nil; l = l.next[0] {
+		if base.Sign() > 0 && sum.Cmp(base) >= 0 ||
+			counter.Sign() > 0 && sum.Cmp(counter) >= 0 {
+			break
+		}
+		levels = append(levels, luno.OrderBookEntry{Price: l.price, Volume: l.volume})
+		if base.Sign() > 0 {
+			sum = sum.Add(l.volume)
+		} else {
+			sum = sum.Add(l.price.Mul(l.volume))
+		}
+	}
+	top := other.depth(1)
+
+	if typ == luno.OrderTypeBuy {
+		return top, levels
+	}
+	return levels, top
 }
@@ -0,0 +1,332 @@
+package streaming
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// naiveSide is a reference implementation of a book side.
+type naiveSide map[string]order
+
+func (m naiveSide) depth(desc bool) []luno.OrderBookEntry {
+	levels := make(map[string]luno.OrderBookEntry)
+	for _, o := range m {
+		k := o.Price.String()
+		l, ok := levels[k]
+		if !ok {
+			l = luno.OrderBookEntry{Price: o.Price, Volume: decimal.Zero()}
+		}
+		l.Volume = l.Volume.Add(o.Volume)
+		levels[k] = l
+	}
+	var ol []luno.OrderBookEntry
+	for _, l := range levels {
+		ol = append(ol, l)
+	}
+	sort.Slice(ol, func(i, j int) bool {
+		if desc {
+			return ol[i].Price.Cmp(ol[j].Price) > 0
+		}
+		return ol[i].Price.Cmp(ol[j].Price) < 0
+	})
+	return ol
+}
+
+// levelStrings formats levels for comparison, since equal decimals may have
+// different representations.
+func levelStrings(ol []luno.OrderBookEntry) []string {
+	var s []string
+	for _, e := range ol {
+		s = append(s, e.Price.String()+"@"+e.Volume.ToScale(8).String())
+	}
+	return s
+}
+
+func TestOrderbookRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var ob orderbookState
+	ob.Set(1, nil, nil)
+	bids, asks := make(naiveSide), make(naiveSide)
+
+	for i := 0; i < 5000; i++ {
+		id := strconv.Itoa(rnd.Intn(500))
+		switch rnd.Intn(3) {
+		case 0:
+			o := order{
+				ID:     id,
+				Price:  decimal.NewFromInt64(int64(rnd.Intn(50) + 1)),
+				Volume: decimal.NewFromInt64(int64(rnd.Intn(10) + 1)),
+			}
+			delete(bids, id)
+			delete(asks, id)
+			if rnd.Intn(2) == 0 {
+				ob.AddOrder(luno.OrderTypeBid, o)
+				bids[id] = o
+			} else {
+				ob.AddOrder(luno.OrderTypeAsk, o)
+				asks[id] = o
+			}
+		case 1:
+			ob.RemoveOrder(id)
+			delete(bids, id)
+			delete(asks, id)
+		case 2:
+			base := decimal.NewFromInt64(1)
+			err := ob.DecrementOrder(id, base)
+			for _, m := range []naiveSide{bids, asks} {
+				o, ok := m[id]
+				if !ok {
+					continue
+				}
+				o.Volume = o.Volume.Sub(base)
+				if o.Volume.Sign() == 0 {
+					delete(m, id)
+				} else {
+					m[id] = o
+				}
+			}
+			_, inBids := bids[id]
+			_, inAsks := asks[id]
+			if err != nil && (inBids || inAsks) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		_, b, a := ob.depth(-1)
+		if exp, act := levelStrings(bids.depth(true)), levelStrings(b); !reflect.DeepEqual(exp, act) {
+			t.Fatalf("Step %d: expected bids %v, got %v", i, exp, act)
+		}
+		if exp, act := levelStrings(asks.depth(false)), levelStrings(a); !reflect.DeepEqual(exp, act) {
+			t.Fatalf("Step %d: expected asks %v, got %v", i, exp, act)
+		}
+		if len(ob.orders) != len(bids)+len(asks) {
+			t.Fatalf("Step %d: expected %d orders, got %d",
+				i, len(bids)+len(asks), len(ob.orders))
+		}
+	}
+}
+
+func TestOrderbookDepth(t *testing.T) {
+	var ob orderbookState
+	ob.Set(5,
+		[]*order{
+			testOrder(t, "b1", "99", "1"),
+			testOrder(t, "b2", "100", "2"),
+			testOrder(t, "b3", "100.00", "0.5"),
+		},
+		[]*order{
+			testOrder(t, "a1", "102", "1"),
+			testOrder(t, "a2", "101", "3"),
+		})
+
+	seq, bids, asks := ob.depth(1)
+	if seq != 5 {
+		t.Errorf("Expected sequence 5, got %d", seq)
+	}
+	if exp, act := []string{"100@2.50000000"}, levelStrings(bids); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected bids %v, got %v", exp, act)
+	}
+	if exp, act := []string{"101@3.00000000"}, levelStrings(asks); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected asks %v, got %v", exp, act)
+	}
+
+	// Orders at the same price are returned in the order they were added.
+	_, bids, _ = ob.GetSnapshot()
+	if exp, act := []string{"100@2.00000000", "100.00@0.50000000", "99@1.00000000"},
+		levelStrings(bids); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected bids %v, got %v", exp, act)
+	}
+}
+
+func TestOrderbookQueries(t *testing.T) {
+	var ob orderbookState
+	if _, ok := ob.best(luno.OrderTypeBid); ok {
+		t.Errorf("Expected no best bid before the snapshot")
+	}
+	if _, ok := ob.midPrice(); ok {
+		t.Errorf("Expected no mid price before the snapshot")
+	}
+	if v := ob.volumeTo(luno.OrderTypeAsk, dec(t, "100")); v.Sign() != 0 {
+		t.Errorf("Expected no volume before the snapshot, got %s", v)
+	}
+
+	ob.Set(5,
+		[]*order{
+			testOrder(t, "b1", "99", "1"),
+			testOrder(t, "b2", "100", "2"),
+			testOrder(t, "b3", "100", "0.5"),
+		},
+		[]*order{
+			testOrder(t, "a1", "102", "1"),
+			testOrder(t, "a2", "101", "3"),
+			testOrder(t, "a3", "105", "7"),
+		})
+
+	type testCase struct {
+		name     string
+		exp, act string
+	}
+	bid, _ := ob.best(luno.OrderTypeBid)
+	ask, _ := ob.best(luno.OrderTypeAsk)
+	spread, _ := ob.spread()
+	mid, _ := ob.midPrice()
+	for _, tc := range []testCase{
+		{"best bid price", "100", bid.Price.String()},
+		{"best bid volume", "2.5", bid.Volume.String()},
+		{"best ask price", "101", ask.Price.String()},
+		{"best ask volume", "3", ask.Volume.String()},
+		{"spread", "1", spread.String()},
+		{"mid price", "100.5", mid.String()},
+		{"bids to 99", "3.5", ob.volumeTo(luno.OrderTypeBid, dec(t, "99")).String()},
+		{"bids to 99.5", "2.5", ob.volumeTo(luno.OrderTypeBid, dec(t, "99.5")).String()},
+		{"bids to 101", "0", ob.volumeTo(luno.OrderTypeBid, dec(t, "101")).String()},
+		{"asks to 102", "4", ob.volumeTo(luno.OrderTypeAsk, dec(t, "102")).String()},
+		{"asks to 1000", "11", ob.volumeTo(luno.OrderTypeAsk, dec(t, "1000")).String()},
+	} {
+		if tc.exp != tc.act {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.exp, tc.act)
+		}
+	}
+}
+
+func TestOrderbookMarketLevels(t *testing.T) {
+	var ob orderbookState
+	ob.Set(5,
+		[]*order{testOrder(t, "b1", "99", "1"), testOrder(t, "b2", "98", "2")},
+		[]*order{
+			testOrder(t, "a1", "101", "1"),
+			testOrder(t, "a2", "102", "0.5"),
+			testOrder(t, "a3", "104", "1.5"),
+		})
+
+	type testCase struct {
+		typ           luno.OrderType
+		base, counter string
+		bids, asks    []string
+	}
+	for _, tc := range []testCase{
+		{
+			typ: luno.OrderTypeBuy, base: "1",
+			bids: []string{"99@1.00000000"}, asks: []string{"101@1.00000000"},
+		},
+		{
+			typ: luno.OrderTypeBuy, base: "1.2",
+			bids: []string{"99@1.00000000"},
+			asks: []string{"101@1.00000000", "102@0.50000000"},
+		},
+		{
+			typ: luno.OrderTypeBuy, counter: "200",
+			bids: []string{"99@1.00000000"},
+			asks: []string{"101@1.00000000", "102@0.50000000", "104@1.50000000"},
+		},
+		{
+			typ: luno.OrderTypeSell, base: "5",
+			bids: []string{"99@1.00000000", "98@2.00000000"},
+			asks: []string{"101@1.00000000"},
+		},
+	} {
+		var base, counter decimal.Decimal
+		if tc.base != "" {
+			base = dec(t, tc.base)
+		}
+		if tc.counter != "" {
+			counter = dec(t, tc.counter)
+		}
+		bids, asks := ob.marketLevels(tc.typ, base, counter)
+		if act := levelStrings(bids); !reflect.DeepEqual(tc.bids, act) {
+			t.Errorf("%s %s%s: expected bids %v, got %v", tc.typ, tc.base, tc.counter, tc.bids, act)
+		}
+		if act := levelStrings(asks); !reflect.DeepEqual(tc.asks, act) {
+			t.Errorf("%s %s%s: expected asks %v, got %v", tc.typ, tc.base, tc.counter, tc.asks, act)
+		}
+
+		// The estimate matches one against the whole book.
+		_, allBids, allAsks := ob.depth(-1)
+		exp, err := luno.EstimateMarketOrder(allBids, allAsks, tc.typ, base, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, err := luno.EstimateMarketOrder(bids, asks, tc.typ, base, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp, act) {
+			t.Errorf("%s %s%s: expected estimate %+v, got %+v", tc.typ, tc.base, tc.counter, exp, act)
+		}
+	}
+}
+
+func loadFixture(b *testing.B) orderbookMessage {
+	var ob orderbookMessage
+	if err := json.Unmarshal(loadFromFile(b, "fixture_orderbook.json"), &ob); err != nil {
+		b.Fatal(err)
+	}
+	return ob
+}
+
+func BenchmarkOrderbookSet(b *testing.B) {
+	msg := loadFixture(b)
+	var ob orderbookState
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ob.Set(msg.Sequence, msg.Bids, msg.Asks)
+	}
+}
+
+func BenchmarkOrderbookGetSnapshot(b *testing.B) {
+	msg := loadFixture(b)
+	var ob orderbookState
+	ob.Set(msg.Sequence, msg.Bids, msg.Asks)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ob.GetSnapshot()
+	}
+}
+
+func BenchmarkOrderbookTopOfBook(b *testing.B) {
+	msg := loadFixture(b)
+	var ob orderbookState
+	ob.Set(msg.Sequence, msg.Bids, msg.Asks)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ob.best(luno.OrderTypeBid)
+		ob.best(luno.OrderTypeAsk)
+	}
+}
+
+func BenchmarkOrderbookDepth10(b *testing.B) {
+	msg := loadFixture(b)
+	var ob orderbookState
+	ob.Set(msg.Sequence, msg.Bids, msg.Asks)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ob.depth(10)
+	}
+}
+
+// BenchmarkOrderbookUpdate adds and removes orders at prices across the book.
+func BenchmarkOrderbookUpdate(b *testing.B) {
+	msg := loadFixture(b)
+	var ob orderbookState
+	ob.Set(msg.Sequence, msg.Bids, msg.Asks)
+	orders := append(append([]*order(nil), msg.Bids...), msg.Asks...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o := *orders[i%len(orders)]
+		o.ID = "bench"
+		ob.AddOrder(luno.OrderTypeAsk, o)
+		ob.RemoveOrder(o.ID)
+	}
+}
@@ -0,0 +1,78 @@
+package streaming
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// frame is a raw message in a recording.
+type frame struct {
+	Time time.Time `json:"time"`
+	Pair string    `json:"pair"`
+	Data string    `json:"data"`
+}
+
+// Recorder writes the raw messages received by connections to a file, for
+// replaying with NewReplay. A Recorder may be shared by several connections,
+// e.g. those of a Manager.
+//
+// The file is gzip-compressed JSON, one frame per line with the time the
+// message was received, the pair and the message. Each Recorder appends a new
+// gzip member to the file, so a file can be recorded to several times and
+// read as one stream.
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	gz  *gzip.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder which appends to the file at path, creating
+// it if needed. The Recorder must be closed when the connections using it
+// are closed.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &Recorder{f: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// record writes a message received for pair at t. Each frame is flushed so
+// that it survives the process crashing. Once writing has failed, record
+// drops frames and returns nil, so that the error is only reported once.
+func (r *Recorder) record(t time.Time, pair string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil
+	}
+	if r.f == nil {
+		r.err = os.ErrClosed
+	} else if r.err = r.enc.Encode(frame{Time: t, Pair: pair, Data: string(data)}); r.err == nil {
+		r.err = r.gz.Flush()
+	}
+	return r.err
+}
+
+// Close finishes the recording and closes the file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	err := r.gz.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.f = nil
+	return err
+}
@@ -0,0 +1,114 @@
+package streaming
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/luno/luno-go"
+)
+
+// MaxSpeed replays a recording as fast as possible.
+const MaxSpeed = 0
+
+// Replay feeds a recording made with a Recorder back through a Connection's
+// message processing, so that its order book, queries and callbacks behave
+// as they did when the messages were received.
+type Replay struct {
+	*Connection
+
+	r     io.Reader
+	speed float64
+}
+
+// NewReplay returns a Replay of the messages recorded for pair in r. speed is
+// the factor by which to speed up the original timing of the messages, e.g. 1
+// for the original timing or 10 for ten times faster, or MaxSpeed. Options
+// which affect the websocket, e.g. WithHost, have no effect.
+func NewReplay(r io.Reader, pair string, speed float64, opts ...DialOption) *Replay {
+	return &Replay{
+		Connection: newConnection(context.Background(), pair, opts),
+		r:          r,
+		speed:      speed,
+	}
+}
+
+// Run replays the recording until its end, or until ctx is done or the
+// Replay is closed. Callbacks are called from Run's goroutine. LastUpdate
+// returns the time the last replayed message was originally received.
+//
+// Messages which fail to process reset the order book, like a disconnection
+// would, until the next snapshot. A book seeded with WithCheckpoint is reset
+// to the checkpoint until a snapshot replaces it.
+func (p *Replay) Run(ctx context.Context) error {
+	gz, err := gzip.NewReader(p.r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+	var start, first time.Time
+	for {
+		var f frame
+		if err := dec.Decode(&f); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if f.Pair != p.pair {
+			continue
+		}
+
+		if p.speed > 0 {
+			if first.IsZero() {
+				start, first = time.Now(), f.Time
+			}
+			due := start.Add(time.Duration(float64(f.Time.Sub(first)) / p.speed))
+			if err := p.sleep(ctx, time.Until(due)); err != nil {
+				return err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		} else if p.ctx.Err() != nil {
+			return ErrConnectionClosed
+		}
+
+		atomic.StoreInt64(&p.lastUpdate, f.Time.UnixNano())
+		err := p.MessageProcessor.HandleMessage([]byte(f.Data))
+		_, violation := err.(*InvariantError)
+		if (err == errSequenceGap || violation) && p.resync {
+			// The processor buffers updates until the next snapshot, which
+			// the recording has if the connection resynced.
+			continue
+		} else if err != nil {
+			p.log(luno.LevelWarn, "Replayed message failed",
+				luno.Field{Key: "error", Value: err})
+			p.MessageProcessor.Reset()
+			p.seed()
+		}
+	}
+}
+
+// sleep waits for d, or until ctx is done or the Replay is closed.
+func (p *Replay) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		if p.ctx.Err() != nil {
+			return ErrConnectionClosed
+		}
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return ErrConnectionClosed
+	}
+}
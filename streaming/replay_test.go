@@ -0,0 +1,171 @@
+package streaming
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl.gz")
+
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1,
+		[]*order{testOrder(t, "b1", "100", "1")},
+		[]*order{testOrder(t, "a1", "101", "2")})
+
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithRecorder(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "b2", Type: "BID", Price: dec(t, "99"), Volume: dec(t, "3"),
+	}})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3, TradeUpdates: []*TradeUpdateMessage{
+		{OrderID: "a1", Base: dec(t, "0.5"), Counter: dec(t, "50.5")},
+	}})
+	waitForSeq(t, c, 3)
+	_, expBids, expAsks := c.Depth(-1)
+	c.Close()
+
+	// Frames of other pairs are skipped.
+	if err := rec.record(time.Now(), "ETHZAR", []byte(`{"sequence":"7","asks":[],"bids":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var trades []Trade
+	r := NewReplay(f, "XBTZAR", MaxSpeed, WithLogger(discardLogger),
+		WithTradeCallback(func(tr Trade) { trades = append(trades, tr) }))
+	defer r.Close()
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, bids, asks := r.Depth(-1)
+	if seq != 3 {
+		t.Errorf("Expected sequence 3, got %d", seq)
+	}
+	if !reflect.DeepEqual(levelStrings(expBids), levelStrings(bids)) ||
+		!reflect.DeepEqual(levelStrings(expAsks), levelStrings(asks)) {
+		t.Errorf("Expected book %v %v, got %v %v", expBids, expAsks, bids, asks)
+	}
+	if len(trades) != 1 || trades[0].Price.String() != "101" || trades[0].Pair != "XBTZAR" {
+		t.Errorf("Expected one trade at 101, got %+v", trades)
+	}
+	if r.LastUpdate().IsZero() {
+		t.Errorf("Expected the time of the last replayed message")
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl.gz")
+
+	// Record twice, to check that appended recordings replay as one.
+	t0 := time.Now()
+	for i, msg := range []string{
+		`{"sequence":"1","asks":[],"bids":[]}`,
+		`{"sequence":"2","timestamp":0}`,
+	} {
+		rec, err := NewRecorder(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.record(t0.Add(time.Duration(i)*200*time.Millisecond), "XBTZAR", []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		speed    float64
+		min, max time.Duration
+	}
+	for _, tc := range []testCase{
+		{speed: 2, min: 100 * time.Millisecond, max: time.Second},
+		{speed: MaxSpeed, max: 150 * time.Millisecond},
+	} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewReplay(f, "XBTZAR", tc.speed, WithLogger(discardLogger))
+		start := time.Now()
+		if err := r.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		d := time.Since(start)
+		f.Close()
+		r.Close()
+
+		if d < tc.min || d > tc.max {
+			t.Errorf("Speed %v: expected replay in %s to %s, took %s", tc.speed, tc.min, tc.max, d)
+		}
+		if seq, _, _ := r.GetSnapshot(); seq != 2 {
+			t.Errorf("Speed %v: expected sequence 2, got %d", tc.speed, seq)
+		}
+	}
+}
+
+func TestReplayCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl.gz")
+
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Now()
+	rec.record(t0, "XBTZAR", []byte(`""`))
+	rec.record(t0.Add(time.Hour), "XBTZAR", []byte(`""`))
+	rec.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r := NewReplay(f, "XBTZAR", 1, WithLogger(discardLogger))
+	time.AfterFunc(20*time.Millisecond, r.Close)
+	if err := r.Run(context.Background()); err != ErrConnectionClosed {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+}
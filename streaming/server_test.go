@@ -0,0 +1,178 @@
+package streaming
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+	"golang.org/x/net/websocket"
+)
+
+// fakeServer is a streaming server which sends a snapshot to every new
+// subscriber, followed by the updates passed to send.
+type fakeServer struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	snapshots map[string]orderbookMessage
+	queued    map[string][]orderbookMessage
+	subs      map[chan interface{}]string
+	conns     int
+}
+
+// newFakeServer starts a server. Connections dialed with its host option
+// connect to it. The caller must call close when done.
+func newFakeServer() *fakeServer {
+	s := &fakeServer{
+		snapshots: make(map[string]orderbookMessage),
+		queued:    make(map[string][]orderbookMessage),
+		subs:      make(map[chan interface{}]string),
+	}
+	s.srv = httptest.NewServer(websocket.Handler(s.serve))
+	return s
+}
+
+func (s *fakeServer) close() {
+	s.srv.Close()
+}
+
+// host returns an option which points a connection at the server.
+func (s *fakeServer) host() DialOption {
+	return WithHost("ws" + strings.TrimPrefix(s.srv.URL, "http"))
+}
+
+// setSnapshot sets the snapshot sent to new subscribers to pair.
+func (s *fakeServer) setSnapshot(pair string, seq int64, bids, asks []*order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[pair] = orderbookMessage{Sequence: seq, Bids: bids, Asks: asks}
+}
+
+// queueSnapshot queues a snapshot to send to the next new subscriber to pair,
+// instead of the one set by setSnapshot.
+func (s *fakeServer) queueSnapshot(pair string, seq int64, bids, asks []*order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued[pair] = append(s.queued[pair],
+		orderbookMessage{Sequence: seq, Bids: bids, Asks: asks})
+}
+
+// open returns the number of subscribers currently connected.
+func (s *fakeServer) open() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// send sends a message to all subscribers to pair.
+func (s *fakeServer) send(pair string, msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch, p := range s.subs {
+		if p == pair {
+			ch <- msg
+		}
+	}
+}
+
+// connections returns the number of connections accepted so far.
+func (s *fakeServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeServer) serve(ws *websocket.Conn) {
+	defer ws.Close()
+	pair := strings.TrimPrefix(ws.Request().URL.Path, "/api/1/stream/")
+
+	var cred credentials
+	if err := websocket.JSON.Receive(ws, &cred); err != nil {
+		return
+	}
+
+	ch := make(chan interface{}, 100)
+	s.mu.Lock()
+	s.conns++
+	snap := s.snapshots[pair]
+	if q := s.queued[pair]; len(q) > 0 {
+		snap, s.queued[pair] = q[0], q[1:]
+	}
+	s.subs[ch] = pair
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+
+	if snap.Bids == nil {
+		snap.Bids = []*order{}
+	}
+	if snap.Asks == nil {
+		snap.Asks = []*order{}
+	}
+	if err := websocket.JSON.Send(ws, snap); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-ch:
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func dec(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func testOrder(t *testing.T, id, price, volume string) *order {
+	return &order{ID: id, Price: dec(t, price), Volume: dec(t, volume)}
+}
+
+// waitFor polls cond until it is true or a timeout expires.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func waitForSeq(t *testing.T, c *Connection, seq int64) {
+	t.Helper()
+	waitFor(t, "sequence "+strconv.FormatInt(seq, 10), func() bool {
+		s, _, _ := c.GetSnapshot()
+		return s >= seq
+	})
+}
@@ -1,6 +1,10 @@
 /*
 Package streaming implements a client for the Luno Streaming API.
 
+A Connection's order book can be saved with Checkpoint and used to seed a
+later Connection or Replay with WithCheckpoint, or a standalone OrderBook
+with NewOrderBook.
+
 Example:
 func main() {
 	callback := func (update streaming.Update) {
@@ -25,160 +29,491 @@ func main() {
 package streaming
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
-	"flag"
-	"log"
-	"math/rand"
+	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/luno/luno-go"
-	"golang.org/x/net/websocket"
+	"github.com/luno/luno-go/decimal"
 )
 
+// ErrConnectionClosed is returned by WaitReady if the connection is closed
+// before it is ready.
+var ErrConnectionClosed = errors.New("streaming: connection closed")
+
+// ErrMaxRetries is returned by WaitReady if the connection gives up after
+// the number of retries set by WithMaxRetries.
+var ErrMaxRetries = errors.New("streaming: too many connection retries")
+
+// ErrStale is the cause of a disconnection when nothing has been received
+// within the stale timeout, or when updates lag by more than it.
+var ErrStale = errors.New("streaming: connection stale")
+
+// DefaultStaleTimeout is how long a connection waits for a message before
+// reconnecting, unless set with WithStaleTimeout.
+const DefaultStaleTimeout = 2 * time.Minute
+
+// DefaultHost is the websocket host of the Luno Streaming API.
+const DefaultHost = "wss://ws.luno.com"
+
+// DefaultOrigin is the origin sent when opening the websocket.
+const DefaultOrigin = "http://localhost/"
+
 type Connection struct {
+	// lastUpdate is the time in unix nanoseconds of the last message, or 0.
+	// It and stats are first for 64-bit alignment of atomic accesses.
+	lastUpdate int64
+	stats      Stats
+
 	keyID, keySecret string
 	pair             string
 
-	ws     *websocket.Conn
-	closed bool
+	// ctx is cancelled when the connection is closed. wg tracks all the
+	// connection's goroutines.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// ready is closed when the first order book snapshot has been applied.
+	ready     chan struct{}
+	readyOnce sync.Once
 
 	MessageProcessor messageProcessor
 
-	mu sync.Mutex
+	logger        luno.Logger
+	backoff       Backoff
+	eventCallback EventCallback
+	recorder      *Recorder
+
+	// auditClient, if set, is used every auditInterval to compare the order
+	// book with the REST order book. The reports are passed to
+	// auditCallback, and a divergence above auditThreshold, if it is not
+	// negative, is sent to resets to resync the book.
+	auditClient    luno.API
+	auditInterval  time.Duration
+	auditCallback  AuditCallback
+	auditThreshold float64
+	resets         chan resetRequest
+
+	// invariantCallback is called with violations of the order book's
+	// invariants, if they are checked.
+	invariantCallback InvariantCallback
+
+	// subs are the connection's subscribers. subsClosed is set when the
+	// connection is closed.
+	subsMu     sync.Mutex
+	subs       []*Subscriber
+	subsClosed bool
+
+	// checkpoint seeds the order book until the first snapshot arrives.
+	checkpoint *Checkpoint
+
+	host, origin string
+	dialer       *net.Dialer
+	tlsConfig    *tls.Config
+	proxy        ProxyFunc
+
+	// resync is set to recover from sequence gaps by resubscribing rather
+	// than reconnecting.
+	resync bool
+
+	// staleTimeout is the longest wait for a message before reconnecting, or
+	// 0 to wait forever.
+	staleTimeout time.Duration
+
+	// maxRetries is the number of consecutive failed connection attempts
+	// after which the connection gives up, or -1 to retry forever. failures
+	// counts the attempts since the last snapshot.
+	maxRetries int
+	failures   int
+
+	// err is the reason the connection closed itself. It is set before
+	// cancel is called.
+	err error
 }
 
 // Dial initiates a Connection to the streaming service and starts processing
 // data for the given market pair.
 // The Connection will automatically reconnect on error.
 func Dial(keyID, keySecret, pair string, opts ...DialOption) (*Connection, error) {
+	return DialContext(context.Background(), keyID, keySecret, pair, opts...)
+}
+
+// DialContext is like Dial, but the Connection is closed when ctx is done.
+// Close must still be called to wait for the connection to shut down.
+func DialContext(ctx context.Context, keyID, keySecret, pair string,
+	opts ...DialOption) (*Connection, error) {
+
 	if keyID == "" || keySecret == "" {
 		return nil, errors.New("streaming: streaming API requires credentials")
 	}
 
+	c := newConnection(ctx, pair, opts)
+	c.keyID, c.keySecret = keyID, keySecret
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.manageForever()
+	}()
+	if c.auditClient != nil && c.auditInterval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.auditForever()
+		}()
+	}
+	return c, nil
+}
+
+// newConnection returns a Connection with the given options applied, which
+// is closed when ctx is done.
+func newConnection(ctx context.Context, pair string, opts []DialOption) *Connection {
 	c := &Connection{
-		keyID:     keyID,
-		keySecret: keySecret,
-		pair:      pair,
+		pair:         pair,
+		ready:        make(chan struct{}),
+		logger:       luno.NewStdLogger(nil, luno.LevelInfo),
+		backoff:      new(backoff),
+		host:         DefaultHost,
+		origin:       DefaultOrigin,
+		maxRetries:   -1,
+		staleTimeout: DefaultStaleTimeout,
+		resync:       true,
+
+		auditThreshold: -1,
+		resets:         make(chan resetRequest, 1),
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.MessageProcessor.checkSnapshot = c.checkCheckpoint
+	c.MessageProcessor.snapshotCallback = c.snapshotReceived
+	c.MessageProcessor.publish = c.publish
+	c.MessageProcessor.invariantCallback = c.invariantViolated
 
-	go c.manageForever()
-	return c, nil
+	if cp := c.checkpoint; cp != nil {
+		err := cp.validate()
+		if err == nil && cp.Pair != pair {
+			err = fmt.Errorf("streaming: checkpoint is for pair %s", cp.Pair)
+		}
+		if err != nil {
+			c.log(luno.LevelError, "Ignoring checkpoint",
+				luno.Field{Key: "error", Value: err})
+			c.checkpoint = nil
+		}
+		c.seed()
+	}
+	return c
 }
 
-var wsHost = flag.String(
-	"luno_websocket_host", "wss://ws.luno.com", "Luno API websocket host")
-
 func (c *Connection) manageForever() {
-	attempts := 0
-	var lastAttempt time.Time
-	for {
-		c.mu.Lock()
-		closed := c.closed
-		c.mu.Unlock()
-		if closed {
+	for c.ctx.Err() == nil {
+		start := time.Now()
+		c.emit(Event{Type: EventConnecting})
+		err := c.connect()
+		if c.ctx.Err() != nil {
+			c.emit(Event{Type: EventDisconnected, Err: ErrConnectionClosed})
 			return
 		}
+		c.log(luno.LevelError, "Connection error",
+			luno.Field{Key: "error", Value: err})
+		c.emit(Event{Type: EventDisconnected, Err: err})
 
-		lastAttempt = time.Now()
-		attempts++
-		if err := c.connect(); err != nil {
-			log.Printf("luno/streaming: Connection error key=%s pair=%s: %v",
-				c.keyID, c.pair, err)
+		c.failures++
+		if c.maxRetries >= 0 && c.failures > c.maxRetries {
+			c.log(luno.LevelError, "Giving up reconnecting",
+				luno.Field{Key: "retries", Value: c.maxRetries})
+			c.err = ErrMaxRetries
+			c.cancel()
+			return
 		}
 
-		if time.Now().Sub(lastAttempt) > time.Hour {
-			attempts = 0
-		}
-		if attempts > 5 {
-			attempts = 5
+		dt := c.backoff.Next(start)
+		c.log(luno.LevelInfo, "Waiting before reconnecting",
+			luno.Field{Key: "wait", Value: dt})
+		c.emit(Event{Type: EventBackoff, Wait: dt})
+		t := time.NewTimer(dt)
+		select {
+		case <-t.C:
+		case <-c.ctx.Done():
+			t.Stop()
 		}
-		wait := 5
-		for i := 0; i < attempts; i++ {
-			wait = 2 * wait
-		}
-		wait = wait + rand.Intn(wait)
-		dt := time.Duration(wait) * time.Second
-		log.Printf("luno/streaming: Waiting %s before reconnecting", dt)
-		time.Sleep(dt)
 	}
 }
 
 func (c *Connection) connect() error {
-	url := *wsHost + "/api/1/stream/" + c.pair
-	ws, err := websocket.Dial(url, "", "http://localhost/")
+	// done stops the subscriptions made by this call, including one still
+	// being dialed, when it returns.
+	done := make(chan struct{})
+	sub, err := c.subscribe(done)
 	if err != nil {
+		close(done)
 		return err
 	}
-
 	defer func() {
-		ws.Close()
-		c.mu.Lock()
-		c.ws = nil
+		close(done)
+		atomic.StoreInt64(&c.lastUpdate, 0)
 		c.MessageProcessor.Reset()
-		c.mu.Unlock()
+		c.seed()
 	}()
 
-	c.mu.Lock()
-	if c.closed {
-		c.mu.Unlock()
-		return nil
-	} else {
-		c.ws = ws
-		c.mu.Unlock()
-	}
+	c.log(luno.LevelInfo, "Connection established")
+	atomic.AddInt64(&c.stats.Connections, 1)
+	c.emit(Event{Type: EventConnected})
 
-	cred := credentials{c.keyID, c.keySecret}
-	if err := websocket.JSON.Send(ws, cred); err != nil {
-		return err
-	}
+	msgs := make(chan wsMessage)
+	c.read(sub, msgs)
 
-	log.Printf("luno/streaming: Connection established key=%s pair=%s",
-		c.keyID, c.pair)
+	// While resyncing, next is the new subscription whose snapshot replaces
+	// the book.
+	var next *subscription
+	dialed := make(chan dialResult, 1)
 
-	go sendPings(ws)
+	// resubscribe dials the subscription which replaces sub, while the
+	// processor buffers updates. A pending subscription whose snapshot did
+	// not resync the book is closed.
+	resubscribe := func(e Event) {
+		if next != nil {
+			next.close()
+			next = nil
+		}
+		c.emit(e)
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			s, err := c.subscribe(done)
+			dialed <- dialResult{s, err}
+		}()
+	}
 
 	for {
-		var data []byte
-		err := websocket.Message.Receive(c.ws, &data)
-		if err != nil {
-			return err
+		var m wsMessage
+		select {
+		case m = <-msgs:
+		case r := <-dialed:
+			if r.err != nil {
+				return r.err
+			}
+			next = r.sub
+			c.read(next, msgs)
+			continue
+		case req := <-c.resets:
+			if c.MessageProcessor.resyncing || req.epoch != c.epoch() {
+				// The book is already being, or has been, replaced.
+				continue
+			} else if !c.resync {
+				return req.err
+			}
+			c.log(luno.LevelWarn, "Resyncing after audit",
+				luno.Field{Key: "error", Value: req.err})
+			c.MessageProcessor.setResyncing()
+			c.MessageProcessor.buffer = nil
+			resubscribe(Event{Type: EventResyncing,
+				Sequence: c.MessageProcessor.orderbook.seq, Err: req.err})
+			continue
+		case <-c.ctx.Done():
+			return c.ctx.Err()
 		}
 
-		err = c.MessageProcessor.HandleMessage(data)
-		if err != nil {
+		if m.sub != sub && m.sub != next {
+			// Left over from a replaced subscription.
+			continue
+		}
+		if m.err != nil && m.sub == sub && c.MessageProcessor.resyncing {
+			// The old subscription is only buffering until the new one's
+			// snapshot arrives.
+			c.log(luno.LevelWarn, "Resync subscription error",
+				luno.Field{Key: "error", Value: m.err})
+			continue
+		} else if m.err != nil {
+			return m.err
+		}
+		now := time.Now()
+		atomic.StoreInt64(&c.lastUpdate, now.UnixNano())
+		if c.recorder != nil {
+			if err := c.recorder.record(now, c.pair, m.data); err != nil {
+				c.log(luno.LevelWarn, "Recording failed",
+					luno.Field{Key: "error", Value: err})
+			}
+		}
+
+		seq := c.MessageProcessor.orderbook.seq
+		err := c.MessageProcessor.HandleMessage(m.data)
+		violation, _ := err.(*InvariantError)
+		if (err == errSequenceGap || violation != nil) && c.resync {
+			e := Event{Type: EventResyncing, Sequence: seq}
+			if violation != nil {
+				e.Sequence, e.Err = violation.Sequence, violation
+				c.log(luno.LevelWarn, "Resyncing after invariant violation",
+					luno.Field{Key: "sequence", Value: e.Sequence})
+			} else {
+				atomic.AddInt64(&c.stats.Gaps, 1)
+				c.log(luno.LevelWarn, "Resyncing after sequence gap",
+					luno.Field{Key: "sequence", Value: seq})
+			}
+			resubscribe(e)
+			continue
+		} else if err != nil {
 			return err
 		}
+
+		if m.sub == next && !c.MessageProcessor.resyncing {
+			// The new subscription's snapshot has been applied.
+			sub.close()
+			sub, next = next, nil
+			atomic.AddInt64(&c.stats.Resyncs, 1)
+			atomic.AddInt64(&c.stats.Replayed, int64(c.MessageProcessor.replayed))
+			c.emit(Event{Type: EventResynced, Sequence: c.MessageProcessor.orderbook.seq})
+		}
+
+		ts := c.MessageProcessor.lastTimestamp
+		if c.staleTimeout > 0 && ts > 0 && !c.MessageProcessor.resyncing &&
+			time.Since(time.Unix(0, ts*1e6)) > c.staleTimeout {
+			return ErrStale
+		}
 	}
 }
 
-func sendPings(ws *websocket.Conn) {
-	defer ws.Close()
-	for {
-		if !sendPing(ws) {
-			return
+// snapshotReceived is called when an order book snapshot has been applied.
+func (c *Connection) snapshotReceived(seq int64) {
+	c.readyOnce.Do(func() { close(c.ready) })
+	c.failures = 0
+	c.emit(Event{Type: EventSnapshotReceived, Sequence: seq})
+}
+
+// WaitReady blocks until the first order book snapshot has been applied. It
+// returns early with an error if ctx is done or the connection is closed.
+func (c *Connection) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return nil
+	default:
+	}
+	select {
+	case <-c.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ctx.Done():
+		if c.err != nil {
+			return c.err
 		}
-		time.Sleep(time.Minute)
+		return ErrConnectionClosed
 	}
 }
 
-func sendPing(ws *websocket.Conn) bool {
-	return websocket.Message.Send(ws, "") == nil
+// log writes a message tagged with the connection's key and pair. The key ID
+// is redacted.
+func (c *Connection) log(level luno.Level, msg string, fields ...luno.Field) {
+	fields = append([]luno.Field{
+		{Key: "key", Value: luno.RedactString(c.keyID)},
+		{Key: "pair", Value: c.pair},
+	}, fields...)
+	c.logger.Log(level, "streaming: "+msg, fields...)
 }
 
+// Close closes the connection and waits for all its goroutines to stop. It
+// must not be called from an update callback.
 func (c *Connection) Close() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.cancel()
+	c.wg.Wait()
+	c.closeSubscribers()
+}
+
+// Stats are counters of a connection's activity since it was dialed.
+type Stats struct {
+	// Connections is the number of times the websocket was established.
+	Connections int64
+	// Gaps is the number of missed updates detected.
+	Gaps int64
+	// Resyncs is the number of gaps recovered from by resubscribing, without
+	// reconnecting.
+	Resyncs int64
+	// Replayed is the number of buffered updates applied after resyncs.
+	Replayed int64
+	// Violations is the number of order book invariant violations found by
+	// WithInvariantCheck.
+	Violations int64
+}
+
+// Stats returns the connection's counters.
+func (c *Connection) Stats() Stats {
+	return Stats{
+		Connections: atomic.LoadInt64(&c.stats.Connections),
+		Gaps:        atomic.LoadInt64(&c.stats.Gaps),
+		Resyncs:     atomic.LoadInt64(&c.stats.Resyncs),
+		Replayed:    atomic.LoadInt64(&c.stats.Replayed),
+		Violations:  atomic.LoadInt64(&c.stats.Violations),
+	}
+}
 
-	c.closed = true
-	if c.ws != nil {
-		c.ws.Close()
+// LastUpdate returns when the last message, including keep-alives, was
+// received from the streaming service. It returns the zero time if nothing
+// has been received since the connection was last (re)established.
+func (c *Connection) LastUpdate() time.Time {
+	ns := atomic.LoadInt64(&c.lastUpdate)
+	if ns == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, ns)
 }
 
+// GetSnapshot returns the order book's sequence and its orders, one entry per
+// order, best price first.
 func (c *Connection) GetSnapshot() (int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
 	return c.MessageProcessor.orderbook.GetSnapshot()
 }
+
+// BestBid returns the highest bid price and the total volume bid at it. ok is
+// false if there are no bids.
+func (c *Connection) BestBid() (level luno.OrderBookEntry, ok bool) {
+	return c.MessageProcessor.orderbook.best(luno.OrderTypeBid)
+}
+
+// BestAsk returns the lowest ask price and the total volume asked at it. ok
+// is false if there are no asks.
+func (c *Connection) BestAsk() (level luno.OrderBookEntry, ok bool) {
+	return c.MessageProcessor.orderbook.best(luno.OrderTypeAsk)
+}
+
+// Spread returns the best ask price less the best bid price. ok is false if
+// either side of the book is empty.
+func (c *Connection) Spread() (spread decimal.Decimal, ok bool) {
+	return c.MessageProcessor.orderbook.spread()
+}
+
+// MidPrice returns the average of the best bid and ask prices. ok is false if
+// either side of the book is empty.
+func (c *Connection) MidPrice() (price decimal.Decimal, ok bool) {
+	return c.MessageProcessor.orderbook.midPrice()
+}
+
+// Depth returns up to n price levels on each side of the book, best first,
+// with the total volume of the orders at each level. All levels are returned
+// if n is negative.
+func (c *Connection) Depth(n int) (int64, []luno.OrderBookEntry, []luno.OrderBookEntry) {
+	return c.MessageProcessor.orderbook.depth(n)
+}
+
+// EstimateMarketOrder simulates a market order against the order book, like
+// luno.EstimateMarketOrder. typ is luno.OrderTypeBuy or luno.OrderTypeSell
+// and exactly one of base or counter must be positive.
+func (c *Connection) EstimateMarketOrder(typ luno.OrderType,
+	base, counter decimal.Decimal) (luno.FillEstimate, error) {
+
+	bids, asks := c.MessageProcessor.orderbook.marketLevels(typ, base, counter)
+	return luno.EstimateMarketOrder(bids, asks, typ, base, counter)
+}
+
+// CumulativeVolume returns the total volume on one side of the book at prices
+// as good as or better than price, i.e. bids at or above price or asks at or
+// below it.
+func (c *Connection) CumulativeVolume(side luno.OrderType, price decimal.Decimal) decimal.Decimal {
+	return c.MessageProcessor.orderbook.volumeTo(side, price)
+}
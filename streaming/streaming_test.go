@@ -0,0 +1,463 @@
+package streaming
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go"
+)
+
+var discardLogger = luno.NewStdLogger(log.New(ioutil.Discard, "", 0), luno.LevelDebug)
+
+func TestWaitReady(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 5, []*order{testOrder(t, "b1", "100", "1")}, nil)
+
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(), WithLogger(discardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if seq, bids, _ := c.GetSnapshot(); seq != 5 || len(bids) != 1 {
+		t.Errorf("Expected snapshot at sequence 5, got %d %v", seq, bids)
+	}
+}
+
+// newBrokenHost starts a server which refuses websockets and returns an
+// option which points connections at it. The caller must call the returned
+// function when done.
+func newBrokenHost() (DialOption, func()) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	return WithHost("ws" + strings.TrimPrefix(srv.URL, "http")), srv.Close
+}
+
+func TestWaitReadyTimeout(t *testing.T) {
+	host, stop := newBrokenHost()
+	defer stop()
+
+	c, err := Dial("key", "secret", "XBTZAR", host, WithLogger(discardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Close interrupts the backoff.
+	start := time.Now()
+	c.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected Close to return promptly, took %s", d)
+	}
+	if err := c.WaitReady(context.Background()); err != ErrConnectionClosed {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestDialContextCancel(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := DialContext(ctx, "key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	c.Close()
+
+	// The server's goroutines for the connection exit once it notices the
+	// connection has gone.
+	waitFor(t, "goroutines to exit", func() bool {
+		return runtime.NumGoroutine() <= before
+	})
+}
+
+// eventRecorder records the types of connection events.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) get() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func eventTypes(events []Event) []EventType {
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestEvents(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 7, nil, nil)
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	events := r.get()
+	exp := []EventType{EventConnecting, EventConnected,
+		EventSnapshotReceived, EventDisconnected}
+	if act := eventTypes(events); !reflect.DeepEqual(exp, act) {
+		t.Fatalf("Expected events %v, got %v", exp, act)
+	}
+	if events[2].Sequence != 7 {
+		t.Errorf("Expected snapshot sequence 7, got %d", events[2].Sequence)
+	}
+	if events[3].Err != ErrConnectionClosed {
+		t.Errorf("Expected ErrConnectionClosed, got %v", events[3].Err)
+	}
+	for _, e := range events {
+		if e.Pair != "XBTZAR" || e.Time.IsZero() {
+			t.Errorf("Expected pair and time to be set, got %+v", e)
+		}
+	}
+}
+
+func TestEventsBackoff(t *testing.T) {
+	host, stop := newBrokenHost()
+	defer stop()
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", host,
+		WithLogger(discardLogger), WithEventCallback(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "backoff", func() bool { return len(r.get()) >= 3 })
+	c.Close()
+
+	events := r.get()
+	exp := []EventType{EventConnecting, EventDisconnected, EventBackoff}
+	if act := eventTypes(events[:3]); !reflect.DeepEqual(exp, act) {
+		t.Fatalf("Expected events %v, got %v", exp, act)
+	}
+	if events[1].Err == nil {
+		t.Errorf("Expected disconnection cause")
+	}
+	if events[2].Wait <= 0 {
+		t.Errorf("Expected positive wait, got %s", events[2].Wait)
+	}
+}
+
+func TestMaxRetries(t *testing.T) {
+	host, stop := newBrokenHost()
+	defer stop()
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", host,
+		WithLogger(discardLogger), WithEventCallback(r.record),
+		WithBackoff(ConstantBackoff(time.Millisecond)), WithMaxRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != ErrMaxRetries {
+		t.Fatalf("Expected ErrMaxRetries, got %v", err)
+	}
+
+	var attempts int
+	for _, e := range r.get() {
+		if e.Type == EventConnecting {
+			attempts++
+		}
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 connection attempts, got %d", attempts)
+	}
+}
+
+// tunnelProxy is an http proxy which tunnels CONNECT requests.
+type tunnelProxy struct {
+	mu      sync.Mutex
+	tunnels []string
+}
+
+func (p *tunnelProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.tunnels = append(p.tunnels, r.Host)
+	p.mu.Unlock()
+
+	dst, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer dst.Close()
+	src, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	io.WriteString(src, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(dst, src); done <- struct{}{} }()
+	go func() { io.Copy(src, dst); done <- struct{}{} }()
+	<-done
+}
+
+func TestProxy(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+
+	var p tunnelProxy
+	proxySrv := httptest.NewServer(&p)
+	defer proxySrv.Close()
+	proxyURL, err := url.Parse(proxySrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithProxy(http.ProxyURL(proxyURL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	exp := []string{strings.TrimPrefix(srv.srv.URL, "http://")}
+	if !reflect.DeepEqual(exp, p.tunnels) {
+		t.Errorf("Expected tunnels %v, got %v", exp, p.tunnels)
+	}
+}
+
+func TestStaleTimeout(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record),
+		WithBackoff(ConstantBackoff(time.Millisecond)),
+		WithStaleTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(c.LastUpdate()); d < 0 || d > time.Second {
+		t.Errorf("Expected a recent last update, got %s ago", d)
+	}
+
+	// The server sends nothing after the snapshot.
+	waitFor(t, "stale disconnection", func() bool {
+		for _, e := range r.get() {
+			if e.Type == EventDisconnected && e.Err == ErrStale {
+				return true
+			}
+		}
+		return false
+	})
+	waitFor(t, "reconnection", func() bool { return srv.connections() >= 2 })
+}
+
+func TestStaleTimestamp(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, nil, nil)
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record),
+		WithStaleTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).UnixNano() / 1e6
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2, Timestamp: old})
+
+	waitFor(t, "stale disconnection", func() bool {
+		for _, e := range r.get() {
+			if e.Type == EventDisconnected {
+				return e.Err == ErrStale
+			}
+		}
+		return false
+	})
+	if !c.LastUpdate().IsZero() {
+		t.Errorf("Expected no last update after disconnection")
+	}
+}
+
+func TestResync(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, []*order{testOrder(t, "b1", "100", "1")}, nil)
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update 2 is lost, so update 3 causes a resync from a snapshot at 2.
+	srv.setSnapshot("XBTZAR", 2, []*order{
+		testOrder(t, "b1", "100", "1"),
+		testOrder(t, "b2", "99", "1"),
+	}, nil)
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3, CreateUpdate: &CreateUpdateMessage{
+		OrderID: "b3", Type: "BID", Price: dec(t, "98"), Volume: dec(t, "1"),
+	}})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 4, DeleteUpdate: &DeleteUpdateMessage{
+		OrderID: "b1",
+	}})
+
+	waitForSeq(t, c, 4)
+	_, bids, _ := c.Depth(-1)
+	if exp, act := []string{"99@1.00000000", "98@1.00000000"}, levelStrings(bids); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected bids %v, got %v", exp, act)
+	}
+
+	stats := c.Stats()
+	if stats.Connections != 1 || stats.Gaps != 1 || stats.Resyncs != 1 || stats.Replayed < 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if n := srv.connections(); n != 2 {
+		t.Errorf("Expected 2 subscriptions, got %d", n)
+	}
+
+	exp := []EventType{EventConnecting, EventConnected, EventSnapshotReceived,
+		EventResyncing, EventSnapshotReceived, EventResynced}
+	if act := eventTypes(r.get()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected events %v, got %v", exp, act)
+	}
+}
+
+func TestResyncGapInReplay(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.queueSnapshot("XBTZAR", 1, nil, nil)
+	srv.queueSnapshot("XBTZAR", 1, nil, nil)
+	srv.setSnapshot("XBTZAR", 3, nil, nil)
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update 2 is lost, and the first resync's snapshot is too old, so
+	// replaying update 3 on it finds the gap again.
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3})
+	waitFor(t, "resync", func() bool { return c.Stats().Resyncs == 1 })
+	waitFor(t, "one subscription", func() bool { return srv.open() == 1 })
+
+	if seq, _, _ := c.GetSnapshot(); seq != 3 {
+		t.Errorf("Expected sequence 3, got %d", seq)
+	}
+	if n := srv.connections(); n != 3 {
+		t.Errorf("Expected 3 subscriptions, got %d", n)
+	}
+	exp := []EventType{EventConnecting, EventConnected, EventSnapshotReceived,
+		EventResyncing, EventSnapshotReceived, EventResyncing, EventSnapshotReceived,
+		EventResynced}
+	if act := eventTypes(r.get()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected events %v, got %v", exp, act)
+	}
+}
+
+func TestResyncDisabled(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.setSnapshot("XBTZAR", 1, nil, nil)
+
+	var r eventRecorder
+	c, err := Dial("key", "secret", "XBTZAR", srv.host(),
+		WithLogger(discardLogger), WithEventCallback(r.record), WithResync(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3})
+	waitFor(t, "disconnection", func() bool { return len(r.get()) >= 4 })
+	if e := r.get()[3]; e.Type != EventDisconnected || e.Err != errSequenceGap {
+		t.Errorf("Expected disconnection by sequence gap, got %+v", e)
+	}
+}
@@ -0,0 +1,197 @@
+package streaming
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSubscriberOverflow is the reason a Subscriber with OverflowDisconnect
+// was closed.
+var ErrSubscriberOverflow = errors.New("streaming: subscriber buffer overflowed")
+
+// OverflowPolicy is what a Subscriber does with an update when its buffer is
+// full. Subscribe treats the zero value, like any other unknown policy, as
+// OverflowDisconnect.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered update to make room.
+	OverflowDropOldest OverflowPolicy = iota + 1
+	// OverflowBlock waits for room, which stalls the connection's processing
+	// of messages, and so its other subscribers and callbacks, meanwhile.
+	OverflowBlock
+	// OverflowDisconnect closes the subscriber with ErrSubscriberOverflow.
+	OverflowDisconnect
+)
+
+// Subscriber receives a connection's updates on a buffered channel. Updates
+// are sent after they are applied to the order book.
+type Subscriber struct {
+	// dropped is first for 64-bit alignment of atomic accesses.
+	dropped int64
+
+	// C receives the updates. It is closed when the subscriber is closed.
+	C <-chan UpdateMessage
+
+	c      chan UpdateMessage
+	policy OverflowPolicy
+	conn   *Connection
+
+	// done is closed when the subscriber is closed, after err is set, to
+	// stop a blocked send. mu is held while sending to c and when closing it.
+	done   chan struct{}
+	once   sync.Once
+	err    error
+	mu     sync.Mutex
+	closed bool
+}
+
+// Subscribe returns a Subscriber with a buffer of size updates, at least 1.
+// Any number of subscribers may be added and closed while the connection
+// runs. If the connection is already closed, the subscriber is too.
+func (c *Connection) Subscribe(size int, policy OverflowPolicy) *Subscriber {
+	if size < 1 {
+		size = 1
+	}
+	if policy < OverflowDropOldest || policy > OverflowDisconnect {
+		policy = OverflowDisconnect
+	}
+	ch := make(chan UpdateMessage, size)
+	s := &Subscriber{
+		C:      ch,
+		c:      ch,
+		policy: policy,
+		conn:   c,
+		done:   make(chan struct{}),
+	}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.subsClosed {
+		s.close(ErrConnectionClosed)
+		return s
+	}
+	c.subs = append(c.subs, s)
+	return s
+}
+
+// Close closes the subscriber and its channel. Buffered updates can still be
+// received.
+func (s *Subscriber) Close() {
+	s.conn.unsubscribe(s)
+	s.close(nil)
+}
+
+// Err returns why the subscriber was closed: ErrSubscriberOverflow,
+// ErrConnectionClosed, or nil if Close was called or it is still open.
+func (s *Subscriber) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Dropped returns the number of updates discarded by OverflowDropOldest.
+func (s *Subscriber) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// close closes the subscriber's channel, once, with the reason err.
+func (s *Subscriber) close(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.c)
+}
+
+// send passes u to the subscriber according to its policy. It reports false
+// if the subscriber overflowed and must be disconnected.
+func (s *Subscriber) send(u UpdateMessage, stop <-chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+
+	switch s.policy {
+	case OverflowBlock:
+		select {
+		case s.c <- u:
+		case <-s.done:
+		case <-stop:
+		}
+		return true
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.c <- u:
+				return true
+			default:
+			}
+			select {
+			case <-s.c:
+				atomic.AddInt64(&s.dropped, 1)
+			default:
+			}
+		}
+
+	default: // OverflowDisconnect
+		select {
+		case s.c <- u:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// publish sends an update to the connection's subscribers.
+func (c *Connection) publish(u UpdateMessage) {
+	c.subsMu.Lock()
+	subs := append([]*Subscriber(nil), c.subs...)
+	c.subsMu.Unlock()
+
+	for _, s := range subs {
+		if !s.send(u, c.ctx.Done()) {
+			c.unsubscribe(s)
+			s.close(ErrSubscriberOverflow)
+		}
+	}
+}
+
+// unsubscribe removes s from the connection's subscribers.
+func (c *Connection) unsubscribe(s *Subscriber) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	for i, sub := range c.subs {
+		if sub == s {
+			c.subs = append(c.subs[:i], c.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// closeSubscribers closes the connection's subscribers, and any added later.
+func (c *Connection) closeSubscribers() {
+	c.subsMu.Lock()
+	subs := c.subs
+	c.subs = nil
+	c.subsClosed = true
+	c.subsMu.Unlock()
+
+	for _, s := range subs {
+		s.close(ErrConnectionClosed)
+	}
+}
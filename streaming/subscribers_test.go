@@ -0,0 +1,133 @@
+package streaming
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func dialTestServer(t *testing.T, srv *fakeServer, opts ...DialOption) *Connection {
+	srv.setSnapshot("XBTZAR", 1, nil, nil)
+	opts = append([]DialOption{srv.host(), WithLogger(discardLogger)}, opts...)
+	c, err := Dial("key", "secret", "XBTZAR", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func receiveAll(s *Subscriber) []int64 {
+	var seqs []int64
+	for u := range s.C {
+		seqs = append(seqs, u.Sequence)
+	}
+	return seqs
+}
+
+func TestSubscribers(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	c := dialTestServer(t, srv)
+	defer c.Close()
+
+	block := c.Subscribe(10, OverflowBlock)
+	drop := c.Subscribe(1, OverflowDropOldest)
+	for seq := int64(2); seq <= 4; seq++ {
+		srv.send("XBTZAR", UpdateMessage{Sequence: seq})
+	}
+	waitFor(t, "dropped updates", func() bool { return drop.Dropped() == 2 })
+
+	block.Close()
+	drop.Close()
+	if exp, act := []int64{2, 3, 4}, receiveAll(block); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected %v, got %v", exp, act)
+	}
+	if exp, act := []int64{4}, receiveAll(drop); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected %v, got %v", exp, act)
+	}
+	if block.Err() != nil || drop.Err() != nil {
+		t.Errorf("Expected no errors, got %v and %v", block.Err(), drop.Err())
+	}
+}
+
+func TestSubscriberDisconnect(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	c := dialTestServer(t, srv)
+	defer c.Close()
+
+	s := c.Subscribe(1, OverflowDisconnect)
+	other := c.Subscribe(10, OverflowBlock)
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 4})
+	waitForSeq(t, c, 4)
+	waitFor(t, "overflow", func() bool { return s.Err() == ErrSubscriberOverflow })
+
+	if exp, act := []int64{2}, receiveAll(s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected %v, got %v", exp, act)
+	}
+	for _, exp := range []int64{2, 3, 4} {
+		if u := <-other.C; u.Sequence != exp {
+			t.Errorf("Expected %d, got %d", exp, u.Sequence)
+		}
+	}
+}
+
+func TestSubscriberDefaultPolicy(t *testing.T) {
+	c := newConnection(context.Background(), "XBTZAR", nil)
+	defer c.Close()
+
+	for _, p := range []OverflowPolicy{0, -1, OverflowDisconnect + 1} {
+		if s := c.Subscribe(1, p); s.policy != OverflowDisconnect {
+			t.Errorf("Expected policy %d to be OverflowDisconnect, got %d", p, s.policy)
+		}
+	}
+}
+
+func TestSubscriberBlockClose(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	c := dialTestServer(t, srv)
+
+	s := c.Subscribe(1, OverflowBlock)
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2})
+	srv.send("XBTZAR", UpdateMessage{Sequence: 3})
+	waitForSeq(t, c, 3)
+
+	// Close must not wait for the blocked subscriber.
+	c.Close()
+	if exp, act := []int64{2}, receiveAll(s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Expected %v, got %v", exp, act)
+	}
+	if s.Err() != ErrConnectionClosed {
+		t.Errorf("Expected %v, got %v", ErrConnectionClosed, s.Err())
+	}
+
+	late := c.Subscribe(1, OverflowBlock)
+	if _, ok := <-late.C; ok || late.Err() != ErrConnectionClosed {
+		t.Errorf("Expected closed subscriber, got %v", late.Err())
+	}
+}
+
+func TestUpdateCallbackUnlocked(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+
+	var c *Connection
+	seqs := make(chan int64, 1)
+	c = dialTestServer(t, srv, WithUpdateCallback(func(u UpdateMessage) {
+		// The book is not locked, so the callback can query it.
+		seq, _, _ := c.GetSnapshot()
+		seqs <- seq
+	}))
+	defer c.Close()
+
+	srv.send("XBTZAR", UpdateMessage{Sequence: 2})
+	if seq := <-seqs; seq != 2 {
+		t.Errorf("Expected sequence 2, got %d", seq)
+	}
+}
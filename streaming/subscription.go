@@ -0,0 +1,130 @@
+package streaming
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// subscription is a websocket subscribed to a connection's pair.
+type subscription struct {
+	ws   *websocket.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+// close stops the subscription's goroutines and closes its websocket.
+func (s *subscription) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// wsMessage is a message, or the error ending the messages, received from a
+// subscription.
+type wsMessage struct {
+	sub  *subscription
+	data []byte
+	err  error
+}
+
+type dialResult struct {
+	sub *subscription
+	err error
+}
+
+// subscribe opens a websocket for the connection's pair and sends the
+// credentials. The subscription is closed when the connection is closed or
+// stop is closed, if not before.
+func (c *Connection) subscribe(stop <-chan struct{}) (*subscription, error) {
+	url := c.host + "/api/1/stream/" + c.pair
+	config, err := websocket.NewConfig(url, c.origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Dialer = c.dialer
+	config.TlsConfig = c.tlsConfig
+	ws, err := dialWebsocket(c.ctx, config, c.proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &subscription{ws: ws, done: make(chan struct{})}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		select {
+		case <-c.ctx.Done():
+		case <-stop:
+		case <-s.done:
+		}
+		s.close()
+		ws.Close()
+	}()
+
+	cred := credentials{c.keyID, c.keySecret}
+	if err := websocket.JSON.Send(ws, cred); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		sendPings(ws, s.done)
+	}()
+	return s, nil
+}
+
+// read sends the messages received from s to msgs, until an error is
+// received or s is closed.
+func (c *Connection) read(s *subscription, msgs chan<- wsMessage) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			if c.staleTimeout > 0 {
+				s.ws.SetReadDeadline(time.Now().Add(c.staleTimeout))
+			}
+
+			var data []byte
+			err := websocket.Message.Receive(s.ws, &data)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = ErrStale
+			}
+
+			select {
+			case msgs <- wsMessage{sub: s, data: data, err: err}:
+			case <-s.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// sendPings keeps the websocket alive until done is closed. It closes the
+// websocket if a ping fails.
+func sendPings(ws *websocket.Conn, done <-chan struct{}) {
+	defer ws.Close()
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		if !sendPing(ws) {
+			return
+		}
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func sendPing(ws *websocket.Conn) bool {
+	return websocket.Message.Send(ws, "") == nil
+}
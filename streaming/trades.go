@@ -0,0 +1,55 @@
+package streaming
+
+import (
+	"time"
+
+	"github.com/luno/luno-go"
+	"github.com/luno/luno-go/decimal"
+)
+
+// Trade is a trade executed against an order in the book.
+type Trade struct {
+	Pair string
+
+	// Sequence and Timestamp are those of the update reporting the trade.
+	Sequence  int64
+	Timestamp time.Time
+
+	// MakerOrderID is the ID of the order traded against, and MakerSide is
+	// its side of the book: luno.OrderTypeBid if the taker sold or
+	// luno.OrderTypeAsk if the taker bought.
+	MakerOrderID string
+	MakerSide    luno.OrderType
+	TakerOrderID string
+
+	// Price is Counter/Base, to the scale of the maker order's price.
+	Price   decimal.Decimal
+	Base    decimal.Decimal
+	Counter decimal.Decimal
+}
+
+// TradeCallback is called with each trade executed in the order book.
+type TradeCallback func(Trade)
+
+// makeTrade returns the trade reported by t in update u, looking up the maker
+// order before it is decremented. ok is false if the order isn't in the book.
+func (ob *orderbookState) makeTrade(u UpdateMessage, t TradeUpdateMessage) (Trade, bool) {
+	o, ok := ob.orders[t.OrderID]
+	if !ok || t.Base.Sign() <= 0 {
+		return Trade{}, false
+	}
+	side := luno.OrderTypeAsk
+	if o.side.desc {
+		side = luno.OrderTypeBid
+	}
+	return Trade{
+		Sequence:     u.Sequence,
+		Timestamp:    time.Unix(0, u.Timestamp*int64(time.Millisecond)),
+		MakerOrderID: t.OrderID,
+		MakerSide:    side,
+		TakerOrderID: t.TakerOrderID,
+		Price:        t.Counter.Div(t.Base, o.Price.Scale()),
+		Base:         t.Base,
+		Counter:      t.Counter,
+	}, true
+}